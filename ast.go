@@ -1,7 +1,12 @@
 package gosql
 
+// Ast is the result of parsing a source with one or more statements.
 type Ast struct {
 	Statements []*Statement
+	// TrailingComments are the comments after the last statement, or every
+	// comment of a source without statements. Only ParseWithComments sets
+	// them.
+	TrailingComments []*Token
 }
 
 type AstKind uint
@@ -10,51 +15,334 @@ const (
 	SelectKind AstKind = iota
 	CreateTableKind
 	InsertKind
+	BeginKind
+	CommitKind
+	RollbackKind
+	SavepointKind
+	RollbackToSavepointKind
+	ReleaseSavepointKind
+	CreateIndexKind
 )
 
+// Statement holds one parsed statement in the field matching its Kind.
+// BEGIN, COMMIT and ROLLBACK are told apart by their Kind alone while the
+// savepoint statements share SavepointStatement.
 type Statement struct {
 	SelectStatement      *SelectStatement
 	CreateTableStatement *CreateTableStatement
 	InsertStatement      *InsertStatement
+	SavepointStatement   *SavepointStatement
+	CreateIndexStatement *CreateIndexStatement
 	Kind                 AstKind
+	// LeadingComments come before the statement or inside it and
+	// TrailingComments after it on the line it ends. Only ParseWithComments
+	// sets them.
+	LeadingComments  []*Token
+	TrailingComments []*Token
 }
 
+// SavepointStatement is SAVEPOINT Name, ROLLBACK TO SAVEPOINT Name or
+// RELEASE SAVEPOINT Name.
+type SavepointStatement struct {
+	Name Token
+}
+
+// InsertStatement is INSERT INTO Table VALUES (Values).
 type InsertStatement struct {
-	table  token
-	values *[]*expression
+	Table  Token
+	Values *[]*Expression
 }
 
-type expressionKind uint
+// ExpressionKind tells which field of an Expression is set.
+type ExpressionKind uint
 
 const (
-	literalKind expressionKind = iota
+	LiteralKind ExpressionKind = iota
+	BinaryKind
+	CallKind
+	CastKind
+	CaseKind
+	InKind
+	BetweenKind
+	LikeKind
 )
 
-type expression struct {
-	literal *token
-	kind    expressionKind
+// BinaryExpression is A Op B for comparisons, AND, OR and the JSON and
+// regex operators.
+type BinaryExpression struct {
+	A  Expression
+	B  Expression
+	Op Token
+}
+
+// CallExpression is a call to the function named by Name.
+type CallExpression struct {
+	Name Token
+	Args *[]*Expression
+	// Asterisk is set for calls like COUNT(*) that take no arguments
+	Asterisk bool
+}
+
+// CastExpression is CAST(Exp AS Datatype) or Exp::Datatype.
+type CastExpression struct {
+	Exp      Expression
+	Datatype Token
+}
+
+// WhenClause is one WHEN When THEN Then branch of a CASE expression.
+type WhenClause struct {
+	When *Expression
+	Then *Expression
+}
+
+// CaseExpression is a searched CASE when Operand is nil and a simple CASE
+// comparing the Operand against each WHEN value otherwise.
+type CaseExpression struct {
+	Operand *Expression
+	Whens   *[]*WhenClause
+	Else    *Expression
+}
+
+// InExpression is Exp [NOT] IN (List).
+type InExpression struct {
+	Exp  Expression
+	List *[]*Expression
+	Not  bool
+}
+
+// BetweenExpression is Exp [NOT] BETWEEN Low AND High.
+type BetweenExpression struct {
+	Exp  Expression
+	Low  Expression
+	High Expression
+	Not  bool
+}
+
+// LikeExpression is Exp [NOT] LIKE Pattern [ESCAPE Escape], where Op is
+// the LIKE or ILIKE keyword.
+type LikeExpression struct {
+	Exp     Expression
+	Pattern Expression
+	Escape  *Expression
+	Op      Token
+	Not     bool
 }
 
-type columnDefinition struct {
-	name     token
-	datatype token
+// Expression is a node of an expression tree. Literal holds identifiers
+// naming columns as well as numbers, strings, booleans and NULL.
+type Expression struct {
+	Literal *Token
+	Binary  *BinaryExpression
+	Call    *CallExpression
+	Cast    *CastExpression
+	Case    *CaseExpression
+	In      *InExpression
+	Between *BetweenExpression
+	Like    *LikeExpression
+	Kind    ExpressionKind
 }
 
+// subexpressions returns the expressions directly nested in e, in source
+// order, skipping optional parts that are absent.
+func (e *Expression) subexpressions() []*Expression {
+	exps := []*Expression{}
+	switch e.Kind {
+	case BinaryKind:
+		exps = append(exps, &e.Binary.A, &e.Binary.B)
+	case CallKind:
+		exps = append(exps, *e.Call.Args...)
+	case CastKind:
+		exps = append(exps, &e.Cast.Exp)
+	case CaseKind:
+		if e.Case.Operand != nil {
+			exps = append(exps, e.Case.Operand)
+		}
+
+		for _, w := range *e.Case.Whens {
+			exps = append(exps, w.When, w.Then)
+		}
+
+		if e.Case.Else != nil {
+			exps = append(exps, e.Case.Else)
+		}
+	case InKind:
+		exps = append(exps, &e.In.Exp)
+		exps = append(exps, *e.In.List...)
+	case BetweenKind:
+		exps = append(exps, &e.Between.Exp, &e.Between.Low, &e.Between.High)
+	case LikeKind:
+		exps = append(exps, &e.Like.Exp, &e.Like.Pattern)
+		if e.Like.Escape != nil {
+			exps = append(exps, e.Like.Escape)
+		}
+	}
+
+	return exps
+}
+
+// ColumnDefinition is a column Name followed by its Datatype keyword.
+type ColumnDefinition struct {
+	Name     Token
+	Datatype Token
+}
+
+// CreateTableStatement is CREATE TABLE Name (Cols).
 type CreateTableStatement struct {
-	name token
-	cols *[]*columnDefinition
+	Name Token
+	Cols *[]*ColumnDefinition
 }
 
+// CreateIndexStatement is CREATE INDEX Name ON Table (Exp).
+type CreateIndexStatement struct {
+	Name  Token
+	Table Token
+	Exp   *Expression
+}
+
+// SelectStatement is a SELECT query. Distinct is set for both DISTINCT
+// and DISTINCT ON, where DistinctOn holds the expressions rows are
+// deduplicated on instead of the whole row.
 type SelectStatement struct {
-	item *[]*selectItem
-	from *fromItem
+	Distinct   bool
+	DistinctOn *[]*Expression
+	Item       *[]*SelectItem
+	From       *FromItem
+	Where      *Expression
+	GroupBy    *[]*Expression
+	OrderBy    *[]*OrderByItem
 }
 
-type selectItem struct {
-	exp      *expression
-	asterisk bool
-	as       *token
+// SelectItem is one column of a select list, either * or an expression
+// with an optional window and output name.
+type SelectItem struct {
+	Exp      *Expression
+	Asterisk bool
+	As       *Token
+	Over     *WindowDefinition
 }
-type fromItem struct {
-	table *token
+
+// OrderByItem is one expression of an ORDER BY list.
+type OrderByItem struct {
+	Exp  *Expression
+	Desc bool
+}
+
+// FrameBoundKind tells where a window frame starts or ends.
+type FrameBoundKind uint
+
+const (
+	UnboundedPrecedingBound FrameBoundKind = iota
+	PrecedingBound
+	CurrentRowBound
+	FollowingBound
+	UnboundedFollowingBound
+)
+
+// FrameBound is a window frame boundary. Offset is only set for
+// PrecedingBound and FollowingBound.
+type FrameBound struct {
+	Kind   FrameBoundKind
+	Offset *Token
+}
+
+// WindowFrame is the ROWS BETWEEN Start AND End clause of a window.
+type WindowFrame struct {
+	Start FrameBound
+	End   FrameBound
+}
+
+// WindowDefinition is the parenthesized window following OVER.
+type WindowDefinition struct {
+	PartitionBy *[]*Expression
+	OrderBy     *[]*OrderByItem
+	Frame       *WindowFrame
+}
+
+// FromItem is the table a select reads from.
+type FromItem struct {
+	Table *Token
+}
+
+// equals reports whether two expressions are structurally identical,
+// ignoring the location of their tokens.
+func (e *Expression) equals(other *Expression) bool {
+	if e.Kind != other.Kind {
+		return false
+	}
+
+	switch e.Kind {
+	case LiteralKind:
+		return e.Literal.equals(other.Literal)
+	case BinaryKind:
+		return e.Binary.Op.equals(&other.Binary.Op) &&
+			e.Binary.A.equals(&other.Binary.A) &&
+			e.Binary.B.equals(&other.Binary.B)
+	case CallKind:
+		if !e.Call.Name.equals(&other.Call.Name) ||
+			e.Call.Asterisk != other.Call.Asterisk ||
+			len(*e.Call.Args) != len(*other.Call.Args) {
+			return false
+		}
+
+		for i, arg := range *e.Call.Args {
+			if !arg.equals((*other.Call.Args)[i]) {
+				return false
+			}
+		}
+
+		return true
+	case CastKind:
+		return e.Cast.Datatype.equals(&other.Cast.Datatype) &&
+			e.Cast.Exp.equals(&other.Cast.Exp)
+	case CaseKind:
+		if !optionalEquals(e.Case.Operand, other.Case.Operand) ||
+			!optionalEquals(e.Case.Else, other.Case.Else) ||
+			len(*e.Case.Whens) != len(*other.Case.Whens) {
+			return false
+		}
+
+		for i, w := range *e.Case.Whens {
+			o := (*other.Case.Whens)[i]
+			if !w.When.equals(o.When) || !w.Then.equals(o.Then) {
+				return false
+			}
+		}
+
+		return true
+	case InKind:
+		if e.In.Not != other.In.Not ||
+			!e.In.Exp.equals(&other.In.Exp) ||
+			len(*e.In.List) != len(*other.In.List) {
+			return false
+		}
+
+		for i, item := range *e.In.List {
+			if !item.equals((*other.In.List)[i]) {
+				return false
+			}
+		}
+
+		return true
+	case BetweenKind:
+		return e.Between.Not == other.Between.Not &&
+			e.Between.Exp.equals(&other.Between.Exp) &&
+			e.Between.Low.equals(&other.Between.Low) &&
+			e.Between.High.equals(&other.Between.High)
+	case LikeKind:
+		return e.Like.Not == other.Like.Not &&
+			e.Like.Op.equals(&other.Like.Op) &&
+			e.Like.Exp.equals(&other.Like.Exp) &&
+			e.Like.Pattern.equals(&other.Like.Pattern) &&
+			optionalEquals(e.Like.Escape, other.Like.Escape)
+	}
+
+	return false
+}
+
+func optionalEquals(a, b *Expression) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.equals(b)
 }
@@ -0,0 +1,42 @@
+package gosql_test
+
+import (
+	"testing"
+
+	"github.com/pdbrito/gosql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAst_Exported(t *testing.T) {
+	ast, err := gosql.Parse("SELECT lower(name) AS n FROM users ORDER BY id DESC")
+	assert.Nil(t, err)
+
+	slct := ast.Statements[0].SelectStatement
+	assert.Equal(t, "users", slct.From.Table.Value)
+	assert.Equal(t, gosql.Location{Line: 0, Col: 29, Offset: 29}, slct.From.Table.Loc)
+
+	item := (*slct.Item)[0]
+	assert.Equal(t, "n", item.As.Value)
+	assert.Equal(t, gosql.CallKind, item.Exp.Kind)
+	assert.Equal(t, "lower", item.Exp.Call.Name.Value)
+
+	arg := (*item.Exp.Call.Args)[0]
+	assert.Equal(t, gosql.LiteralKind, arg.Kind)
+	assert.Equal(t, gosql.IdentifierKind, arg.Literal.Kind)
+	assert.Equal(t, "name", arg.Literal.Value)
+
+	orderBy := (*slct.OrderBy)[0]
+	assert.Equal(t, "id", orderBy.Exp.Literal.Value)
+	assert.True(t, orderBy.Desc)
+
+	ast, err = gosql.Parse("CREATE TABLE users (id INT, name TEXT)")
+	assert.Nil(t, err)
+
+	create := ast.Statements[0].CreateTableStatement
+	assert.Equal(t, "users", create.Name.Value)
+	var cols []string
+	for _, col := range *create.Cols {
+		cols = append(cols, col.Name.Value+" "+col.Datatype.Value)
+	}
+	assert.Equal(t, []string{"id int", "name text"}, cols)
+}
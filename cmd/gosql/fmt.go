@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pdbrito/gosql"
+)
+
+// formatCommand implements `gosql fmt [flags] [file ...]`, printing each
+// file, or stdin when there are none, as canonical SQL.
+func formatCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write result to the source file instead of stdout")
+	lower := flags.Bool("lower", false, "print keywords in lower case")
+	indent := flags.Int("indent", 2, "number of spaces to indent wrapped clause items by")
+	width := flags.Int("width", gosql.DefaultFormatter.LineWidth, "line width to wrap statements at, 0 to never wrap")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gosql fmt [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	formatter := gosql.Formatter{
+		LowerKeywords: *lower,
+		Indent:        strings.Repeat(" ", *indent),
+		LineWidth:     *width,
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "gosql fmt: cannot use -w with standard input")
+			return 2
+		}
+
+		source, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		formatted, err := formatSource(formatter, string(source))
+		if err != nil {
+			reportError("<stdin>", err)
+			return 1
+		}
+
+		fmt.Print(formatted)
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		formatted, err := formatSource(formatter, string(source))
+		if err != nil {
+			reportError(path, err)
+			status = 1
+			continue
+		}
+
+		if !*write {
+			fmt.Print(formatted)
+			continue
+		}
+
+		if formatted != string(source) {
+			if err := ioutil.WriteFile(path, []byte(formatted), 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+			}
+		}
+	}
+
+	return status
+}
+
+// reportError prints every syntax error in a source on its own line.
+func reportError(path string, err error) {
+	if errs, ok := err.(gosql.ParseErrors); ok {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+}
+
+func formatSource(formatter gosql.Formatter, source string) (string, error) {
+	ast, err := gosql.ParseWithComments(source)
+	if err != nil {
+		return "", err
+	}
+
+	if len(ast.Statements) == 0 && len(ast.TrailingComments) == 0 {
+		return "", nil
+	}
+
+	return formatter.Format(ast) + "\n", nil
+}
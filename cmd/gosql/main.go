@@ -10,8 +10,16 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(formatCommand(os.Args[2:]))
+	}
+
 	mb := gosql.NewMemoryBackend()
 
+	// Statements run against the open transaction, if any
+	var backend gosql.BackEnd = mb
+	var tx *gosql.Tx
+
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Println("Welcome to gosql.")
 	for {
@@ -28,20 +36,71 @@ func main() {
 
 		for _, stmt := range ast.Statements {
 			switch stmt.Kind {
+			case gosql.BeginKind:
+				if tx != nil {
+					log.Panic("there is already a transaction in progress")
+				}
+				tx = mb.Begin()
+				backend = tx
+				fmt.Println("ok")
+			case gosql.CommitKind, gosql.RollbackKind:
+				if tx == nil {
+					log.Panic("there is no transaction in progress")
+				}
+
+				if stmt.Kind == gosql.CommitKind {
+					err = tx.Commit()
+				} else {
+					err = tx.Rollback()
+				}
+				tx = nil
+				backend = mb
+				if err != nil {
+					log.Panic(err)
+				}
+				fmt.Println("ok")
+			case gosql.SavepointKind, gosql.RollbackToSavepointKind, gosql.ReleaseSavepointKind:
+				if tx == nil {
+					log.Panic("savepoints can only be used in transactions")
+				}
+
+				name := stmt.SavepointStatement.Name.Value
+				switch stmt.Kind {
+				case gosql.SavepointKind:
+					err = tx.Savepoint(name)
+				case gosql.RollbackToSavepointKind:
+					err = tx.RollbackTo(name)
+				case gosql.ReleaseSavepointKind:
+					err = tx.Release(name)
+				}
+				if err != nil {
+					log.Panic(err)
+				}
+				fmt.Println("ok")
 			case gosql.CreateTableKind:
-				err := mb.CreateTable(stmt.CreateTableStatement)
+				err := backend.CreateTable(stmt.CreateTableStatement)
+				if err != nil {
+					log.Panic(err)
+				}
+				fmt.Println("ok")
+			case gosql.CreateIndexKind:
+				if tx != nil {
+					err = tx.CreateIndex(stmt.CreateIndexStatement)
+				} else {
+					err = mb.CreateIndex(stmt.CreateIndexStatement)
+				}
 				if err != nil {
 					log.Panic(err)
 				}
 				fmt.Println("ok")
 			case gosql.InsertKind:
-				err = mb.Insert(stmt.InsertStatement)
+				err = backend.Insert(stmt.InsertStatement)
 				if err != nil {
 					log.Panic(err)
 				}
 				fmt.Println("ok")
 			case gosql.SelectKind:
-				results, err := mb.Select(stmt.SelectStatement)
+				results, err := backend.Select(stmt.SelectStatement)
 				if err != nil {
 					log.Panic(err)
 				}
@@ -60,10 +119,22 @@ func main() {
 					for i, cell := range result {
 						typ := results.Columns[i].Type
 						s := ""
-						switch typ {
-						case gosql.IntType:
-							s = fmt.Sprintf("%d", cell.AsInt())
-						case gosql.TextType:
+						switch {
+						case cell.IsNull():
+							s = "NULL"
+						case typ == gosql.IntType:
+							i, err := cell.AsInt()
+							if err != nil {
+								log.Panic(err)
+							}
+							s = fmt.Sprintf("%d", i)
+						case typ == gosql.BoolType:
+							b, err := cell.(gosql.BoolCell).AsBool()
+							if err != nil {
+								log.Panic(err)
+							}
+							s = fmt.Sprintf("%t", b)
+						case typ == gosql.TextType, typ == gosql.JSONType:
 							s = cell.AsText()
 						}
 
@@ -0,0 +1,378 @@
+package gosql
+
+import (
+	"strings"
+)
+
+// Formatter prints an Ast as canonical SQL text that parses back into an
+// equivalent Ast.
+type Formatter struct {
+	// LowerKeywords prints keywords in lower case instead of upper case
+	LowerKeywords bool
+	// Indent precedes the items of a clause broken over several lines
+	Indent string
+	// LineWidth is the length beyond which a statement is broken into one
+	// line per clause, and a clause into one line per item. Zero keeps
+	// every statement on a single line.
+	LineWidth int
+}
+
+// DefaultFormatter is the Formatter used by Format.
+var DefaultFormatter = Formatter{Indent: "  ", LineWidth: 80}
+
+// Format prints an Ast with the DefaultFormatter.
+func Format(ast *Ast) string {
+	return DefaultFormatter.Format(ast)
+}
+
+// Format prints every statement of an Ast terminated by a semicolon, one
+// statement after another. Leading comments are printed on lines of their
+// own before their statement and trailing ones after its semicolon.
+func (f Formatter) Format(ast *Ast) string {
+	var lines []string
+	for _, stmt := range ast.Statements {
+		for _, comment := range stmt.LeadingComments {
+			lines = append(lines, comment.Value)
+		}
+
+		line := f.statement(stmt) + ";"
+		for _, comment := range stmt.TrailingComments {
+			line += " " + comment.Value
+		}
+		lines = append(lines, line)
+	}
+
+	for _, comment := range ast.TrailingComments {
+		lines = append(lines, comment.Value)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// clause is a keyword followed by a comma separated list of items, which
+// are wrapped in parens when parenthesized is set.
+type clause struct {
+	head          string
+	items         []string
+	parenthesized bool
+}
+
+func (c clause) line() string {
+	items := strings.Join(c.items, ", ")
+	if c.parenthesized {
+		return c.head + " (" + items + ")"
+	}
+
+	if len(c.items) == 0 {
+		return c.head
+	}
+
+	return c.head + " " + items
+}
+
+func (f Formatter) fits(line string) bool {
+	return f.LineWidth <= 0 || len(line) <= f.LineWidth
+}
+
+func (f Formatter) layout(clauses []clause) string {
+	var lines []string
+	for _, c := range clauses {
+		lines = append(lines, c.line())
+	}
+
+	if single := strings.Join(lines, " "); f.fits(single) {
+		return single
+	}
+
+	for i, c := range clauses {
+		if f.fits(lines[i]) || len(c.items) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		b.WriteString(c.head)
+		if c.parenthesized {
+			b.WriteString(" (")
+		}
+
+		for j, item := range c.items {
+			b.WriteString("\n" + f.Indent + item)
+			if j < len(c.items)-1 {
+				b.WriteString(",")
+			}
+		}
+
+		if c.parenthesized {
+			b.WriteString("\n)")
+		}
+
+		lines[i] = b.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (f Formatter) keyword(k string) string {
+	if f.LowerKeywords {
+		return strings.ToLower(k)
+	}
+
+	return strings.ToUpper(k)
+}
+
+func (f Formatter) statement(stmt *Statement) string {
+	switch stmt.Kind {
+	case SelectKind:
+		return f.selectStatement(stmt.SelectStatement)
+	case InsertKind:
+		inst := stmt.InsertStatement
+		return f.layout([]clause{{
+			head:          f.keyword("INSERT INTO ") + inst.Table.Value + f.keyword(" VALUES"),
+			items:         f.expressions(inst.Values),
+			parenthesized: true,
+		}})
+	case CreateTableKind:
+		crt := stmt.CreateTableStatement
+
+		var cols []string
+		if crt.Cols != nil {
+			for _, col := range *crt.Cols {
+				cols = append(cols, col.Name.Value+" "+f.keyword(col.Datatype.Value))
+			}
+		}
+
+		return f.layout([]clause{{
+			head:          f.keyword("CREATE TABLE ") + crt.Name.Value,
+			items:         cols,
+			parenthesized: true,
+		}})
+	case CreateIndexKind:
+		crt := stmt.CreateIndexStatement
+		return f.keyword("CREATE INDEX ") + crt.Name.Value + f.keyword(" ON ") + crt.Table.Value +
+			" (" + f.expression(crt.Exp) + ")"
+	case BeginKind:
+		return f.keyword("BEGIN")
+	case CommitKind:
+		return f.keyword("COMMIT")
+	case RollbackKind:
+		return f.keyword("ROLLBACK")
+	case SavepointKind:
+		return f.keyword("SAVEPOINT ") + stmt.SavepointStatement.Name.Value
+	case RollbackToSavepointKind:
+		return f.keyword("ROLLBACK TO SAVEPOINT ") + stmt.SavepointStatement.Name.Value
+	case ReleaseSavepointKind:
+		return f.keyword("RELEASE SAVEPOINT ") + stmt.SavepointStatement.Name.Value
+	}
+
+	return ""
+}
+
+func (f Formatter) selectStatement(slct *SelectStatement) string {
+	head := f.keyword("SELECT")
+	if slct.Distinct {
+		head += " " + f.keyword("DISTINCT")
+		if slct.DistinctOn != nil {
+			head += " " + f.keyword("ON") + " (" + strings.Join(f.expressions(slct.DistinctOn), ", ") + ")"
+		}
+	}
+
+	var items []string
+	if slct.Item != nil {
+		for _, item := range *slct.Item {
+			items = append(items, f.selectItem(item))
+		}
+	}
+	clauses := []clause{{head: head, items: items}}
+
+	if slct.From != nil && slct.From.Table != nil {
+		clauses = append(clauses, clause{head: f.keyword("FROM"), items: []string{slct.From.Table.Value}})
+	}
+
+	if slct.Where != nil {
+		clauses = append(clauses, clause{head: f.keyword("WHERE"), items: []string{f.expression(slct.Where)}})
+	}
+
+	if slct.GroupBy != nil {
+		clauses = append(clauses, clause{head: f.keyword("GROUP BY"), items: f.expressions(slct.GroupBy)})
+	}
+
+	if slct.OrderBy != nil {
+		clauses = append(clauses, clause{head: f.keyword("ORDER BY"), items: f.orderBy(slct.OrderBy)})
+	}
+
+	return f.layout(clauses)
+}
+
+func (f Formatter) selectItem(item *SelectItem) string {
+	if item.Asterisk {
+		return "*"
+	}
+
+	s := f.expression(item.Exp)
+	if item.Over != nil {
+		s += " " + f.keyword("OVER") + " (" + f.window(item.Over) + ")"
+	}
+
+	if item.As != nil {
+		s += " " + f.keyword("AS") + " " + item.As.Value
+	}
+
+	return s
+}
+
+func (f Formatter) window(window *WindowDefinition) string {
+	var parts []string
+	if window.PartitionBy != nil {
+		parts = append(parts, f.keyword("PARTITION BY")+" "+strings.Join(f.expressions(window.PartitionBy), ", "))
+	}
+
+	if window.OrderBy != nil {
+		parts = append(parts, f.keyword("ORDER BY")+" "+strings.Join(f.orderBy(window.OrderBy), ", "))
+	}
+
+	if window.Frame != nil {
+		parts = append(parts, f.keyword("ROWS BETWEEN")+" "+f.frameBound(window.Frame.Start)+
+			" "+f.keyword("AND")+" "+f.frameBound(window.Frame.End))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (f Formatter) frameBound(bound FrameBound) string {
+	switch bound.Kind {
+	case UnboundedPrecedingBound:
+		return f.keyword("UNBOUNDED PRECEDING")
+	case PrecedingBound:
+		return bound.Offset.Value + " " + f.keyword("PRECEDING")
+	case FollowingBound:
+		return bound.Offset.Value + " " + f.keyword("FOLLOWING")
+	case UnboundedFollowingBound:
+		return f.keyword("UNBOUNDED FOLLOWING")
+	}
+
+	return f.keyword("CURRENT ROW")
+}
+
+func (f Formatter) orderBy(items *[]*OrderByItem) []string {
+	var s []string
+	for _, item := range *items {
+		exp := f.expression(item.Exp)
+		if item.Desc {
+			exp += " " + f.keyword("DESC")
+		}
+
+		s = append(s, exp)
+	}
+
+	return s
+}
+
+func (f Formatter) expressions(exps *[]*Expression) []string {
+	var s []string
+	if exps == nil {
+		return s
+	}
+
+	for _, exp := range *exps {
+		s = append(s, f.expression(exp))
+	}
+
+	return s
+}
+
+// atomBindingPower is the precedence of expressions that never need
+// parens, binding tighter than any operator.
+const atomBindingPower = ^uint(0)
+
+func precedence(e *Expression) uint {
+	switch e.Kind {
+	case BinaryKind:
+		return e.Binary.Op.bindingPower()
+	case InKind, BetweenKind, LikeKind:
+		return predicateBindingPower
+	}
+
+	return atomBindingPower
+}
+
+// operand prints an operand of an operator with binding power bp,
+// wrapping it in parens when it binds more loosely. Operators are left
+// associative, so right operands are also wrapped when they bind equally.
+func (f Formatter) operand(e *Expression, bp uint, right bool) string {
+	p := precedence(e)
+	if p < bp || (right && p == bp) {
+		return "(" + f.expression(e) + ")"
+	}
+
+	return f.expression(e)
+}
+
+func (f Formatter) not(not bool) string {
+	if not {
+		return f.keyword("NOT") + " "
+	}
+
+	return ""
+}
+
+func (f Formatter) expression(e *Expression) string {
+	switch e.Kind {
+	case LiteralKind:
+		switch e.Literal.Kind {
+		case StringKind:
+			return "'" + strings.ReplaceAll(e.Literal.Value, "'", "''") + "'"
+		case BoolKind, NullKind:
+			return f.keyword(e.Literal.Value)
+		}
+
+		return e.Literal.Value
+	case BinaryKind:
+		op := e.Binary.Op.Value
+		if e.Binary.Op.Kind == KeywordKind {
+			op = f.keyword(op)
+		}
+
+		bp := e.Binary.Op.bindingPower()
+		return f.operand(&e.Binary.A, bp, false) + " " + op + " " + f.operand(&e.Binary.B, bp, true)
+	case CallKind:
+		if e.Call.Asterisk {
+			return e.Call.Name.Value + "(*)"
+		}
+
+		return e.Call.Name.Value + "(" + strings.Join(f.expressions(e.Call.Args), ", ") + ")"
+	case CastKind:
+		return f.keyword("CAST") + "(" + f.expression(&e.Cast.Exp) + " " + f.keyword("AS") + " " + f.keyword(e.Cast.Datatype.Value) + ")"
+	case CaseKind:
+		parts := []string{f.keyword("CASE")}
+		if e.Case.Operand != nil {
+			parts = append(parts, f.expression(e.Case.Operand))
+		}
+
+		for _, w := range *e.Case.Whens {
+			parts = append(parts, f.keyword("WHEN"), f.expression(w.When), f.keyword("THEN"), f.expression(w.Then))
+		}
+
+		if e.Case.Else != nil {
+			parts = append(parts, f.keyword("ELSE"), f.expression(e.Case.Else))
+		}
+
+		return strings.Join(append(parts, f.keyword("END")), " ")
+	case InKind:
+		return f.operand(&e.In.Exp, predicateBindingPower, false) + " " + f.not(e.In.Not) +
+			f.keyword("IN") + " (" + strings.Join(f.expressions(e.In.List), ", ") + ")"
+	case BetweenKind:
+		return f.operand(&e.Between.Exp, predicateBindingPower, false) + " " + f.not(e.Between.Not) +
+			f.keyword("BETWEEN") + " " + f.operand(&e.Between.Low, predicateBindingPower, true) +
+			" " + f.keyword("AND") + " " + f.operand(&e.Between.High, predicateBindingPower, true)
+	case LikeKind:
+		s := f.operand(&e.Like.Exp, predicateBindingPower, false) + " " + f.not(e.Like.Not) +
+			f.keyword(e.Like.Op.Value) + " " + f.operand(&e.Like.Pattern, predicateBindingPower, true)
+		if e.Like.Escape != nil {
+			s += " " + f.keyword("ESCAPE") + " " + f.operand(e.Like.Escape, predicateBindingPower, true)
+		}
+
+		return s
+	}
+
+	return ""
+}
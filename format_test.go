@@ -0,0 +1,166 @@
+package gosql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clearLocations zeroes the location of every token reachable from v so
+// that ASTs parsed from differently laid out sources can be compared.
+func clearLocations(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			clearLocations(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearLocations(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(Location{}) {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			clearLocations(v.Field(i))
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		source    string
+		formatted string
+	}{
+		{
+			source:    "select a,b from t",
+			formatted: "SELECT a, b FROM t;",
+		},
+		{
+			source:    "create table t (id int, doc json); insert into t values (1, '{}')",
+			formatted: "CREATE TABLE t (id INT, doc JSON);\nINSERT INTO t VALUES (1, '{}');",
+		},
+		{
+			source:    "SELECT (a = 1 OR b = 2) AND c, a = (b = c), NOT_A_KEYWORD",
+			formatted: "SELECT (a = 1 OR b = 2) AND c, a = (b = c), not_a_keyword;",
+		},
+		{
+			source:    "SELECT x::int, CAST(y AS text), doc->'a'->>0",
+			formatted: "SELECT CAST(x AS INT), CAST(y AS TEXT), doc -> 'a' ->> 0;",
+		},
+		{
+			source:    "SELECT a NOT IN (1, 2), a BETWEEN (b AND c) AND d, a ILIKE 'x!%' ESCAPE '!', a ~ '^b'",
+			formatted: "SELECT\n  a NOT IN (1, 2),\n  a BETWEEN (b AND c) AND d,\n  a ILIKE 'x!%' ESCAPE '!',\n  a ~ '^b';",
+		},
+		{
+			source:    "SELECT CASE WHEN a THEN 'x' ELSE NULL END, CASE a WHEN true THEN false END",
+			formatted: "SELECT CASE WHEN a THEN 'x' ELSE NULL END, CASE a WHEN TRUE THEN FALSE END;",
+		},
+		{
+			source:    "create index by_name on t (name)",
+			formatted: "CREATE INDEX by_name ON t (name);",
+		},
+		{
+			source:    "select a from t where a > 1 and b like 'x%' order by a",
+			formatted: "SELECT a FROM t WHERE a > 1 AND b LIKE 'x%' ORDER BY a;",
+		},
+		{
+			source:    "SELECT DISTINCT ON (a) a, count(*) AS n FROM t GROUP BY a ORDER BY a, n DESC",
+			formatted: "SELECT DISTINCT ON (a) a, count(*) AS n FROM t GROUP BY a ORDER BY a, n DESC;",
+		},
+		{
+			source:    "SELECT sum(a) OVER (PARTITION BY b ORDER BY c DESC ROWS 2 PRECEDING) FROM t",
+			formatted: "SELECT\n  sum(a) OVER (PARTITION BY b ORDER BY c DESC ROWS BETWEEN 2 PRECEDING AND CURRENT ROW)\nFROM t;",
+		},
+		{
+			source:    `SELECT 'a '' b', E'c\'d', $$e'f$$`,
+			formatted: "SELECT 'a '' b', 'c''d', 'e''f';",
+		},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		assert.Nil(t, err, test.source)
+
+		formatted := Format(ast)
+		assert.Equal(t, test.formatted, formatted, test.source)
+
+		reparsed, err := Parse(formatted)
+		assert.Nil(t, err, formatted)
+
+		clearLocations(reflect.ValueOf(ast))
+		clearLocations(reflect.ValueOf(reparsed))
+		assert.Equal(t, ast, reparsed, formatted)
+	}
+}
+
+func TestFormat_Comments(t *testing.T) {
+	tests := []struct {
+		source    string
+		formatted string
+	}{
+		{
+			"-- only a comment",
+			"-- only a comment",
+		},
+		{
+			"-- users\nCREATE TABLE users (id INT); -- done\nSELECT id FROM users",
+			"-- users\nCREATE TABLE users (id INT); -- done\nSELECT id FROM users;",
+		},
+		{
+			"SELECT /* inside */ id FROM users; /* a */ /* b */\n\n/* before\n the insert */ INSERT INTO users VALUES (1);\n-- the end",
+			"/* inside */\nSELECT id FROM users; /* a */ /* b */\n/* before\n the insert */\nINSERT INTO users VALUES (1);\n-- the end",
+		},
+		{
+			"SELECT 1; SELECT /* second */ 2;; -- after both\n",
+			"SELECT 1;\n/* second */\nSELECT 2; -- after both",
+		},
+		{
+			"SELECT 'multi\nline' -- no semicolon",
+			"SELECT 'multi\nline'; -- no semicolon",
+		},
+	}
+
+	for _, test := range tests {
+		ast, err := ParseWithComments(test.source)
+		assert.Nil(t, err, test.source)
+
+		formatted := Format(ast)
+		assert.Equal(t, test.formatted, formatted, test.source)
+
+		reparsed, err := ParseWithComments(formatted)
+		assert.Nil(t, err, formatted)
+		assert.Equal(t, formatted, Format(reparsed), formatted)
+
+		clearLocations(reflect.ValueOf(ast))
+		clearLocations(reflect.ValueOf(reparsed))
+		assert.Equal(t, ast, reparsed, formatted)
+	}
+
+	// Parse keeps dropping comments
+	ast, err := Parse("-- users\nSELECT 1; -- one")
+	assert.Nil(t, err)
+	assert.Nil(t, ast.Statements[0].LeadingComments)
+	assert.Nil(t, ast.Statements[0].TrailingComments)
+}
+
+func TestFormatter_Format(t *testing.T) {
+	ast, err := Parse("SELECT first_name, last_name, lower(email) AS email FROM users ORDER BY last_name; INSERT INTO users VALUES ('a', 'b', 'c')")
+	assert.Nil(t, err)
+
+	f := Formatter{LowerKeywords: true, Indent: "    ", LineWidth: 40}
+	assert.Equal(t, `select
+    first_name,
+    last_name,
+    lower(email) as email
+from users
+order by last_name;
+insert into users values ('a', 'b', 'c');`, f.Format(ast))
+
+	f.LineWidth = 0
+	assert.Equal(t, "select first_name, last_name, lower(email) as email from users order by last_name;\ninsert into users values ('a', 'b', 'c');", f.Format(ast))
+}
@@ -0,0 +1,56 @@
+package gosql
+
+import (
+	"strings"
+)
+
+// lexComment lexes a line comment running from -- to the end of the line
+// or a block comment between /* and */, where block comments may nest.
+// The returned token holds the comment including its delimiters.
+func lexComment(source string, ic cursor) (*Token, cursor, bool) {
+	rest := source[ic.pointer:]
+	end := ic.pointer
+
+	switch {
+	case strings.HasPrefix(rest, "--"):
+		length := strings.IndexAny(rest, "\r\n")
+		if length == -1 {
+			length = len(rest)
+		}
+
+		end += uint(length)
+	case strings.HasPrefix(rest, "/*"):
+		depth := 0
+		for {
+			if end >= uint(len(source)) {
+				// Unterminated
+				return nil, ic, false
+			}
+
+			rest = source[end:]
+			switch {
+			case strings.HasPrefix(rest, "/*"):
+				depth++
+				end += 2
+				continue
+			case strings.HasPrefix(rest, "*/"):
+				depth--
+				end += 2
+			default:
+				end++
+			}
+
+			if depth == 0 {
+				break
+			}
+		}
+	default:
+		return nil, ic, false
+	}
+
+	return &Token{
+		Value: source[ic.pointer:end],
+		Kind:  CommentKind,
+		Loc:   ic.loc,
+	}, ic.advance(source, end), true
+}
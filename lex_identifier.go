@@ -2,52 +2,60 @@ package gosql
 
 import (
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // lexIdentifier will lex identifiers which are defined as a double quoted
-// string or a group of characters starting with an alphabetic character and
-// possibly containing numbers, underscores and dollar signs.
-// E.g. "identifier", identifier and ident_$2 are all valid.
-func lexIdentifier(source string, ic cursor) (*token, cursor, bool) {
+// string or a group of characters starting with a letter and possibly
+// containing numbers, underscores and dollar signs. Letters may be any
+// Unicode letter.
+// E.g. "identifier", identifier, ident_$2 and café are all valid.
+func lexIdentifier(source string, ic cursor) (*Token, cursor, bool) {
 	// Handle separately if double quoted identifier
 	if token, newCursor, ok := lexCharacterDelimited(source, ic, '"'); ok {
 		return token, newCursor, true
 	}
 
-	cur := ic
-
-	c := source[cur.pointer]
-
-	isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
-	if !isAlphabetical {
+	pointer := scanWord(source, ic.pointer)
+	if pointer == ic.pointer {
 		return nil, ic, false
 	}
-	cur.pointer++
-	cur.loc.col++
-
-	value := []byte{c}
-	for ; cur.pointer < uint(len(source)); cur.pointer++ {
-		c = source[cur.pointer]
-
-		isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
-		isNumeric := c >= '0' && c <= '9'
-
-		if isAlphabetical || isNumeric || c == '$' || c == '_' {
-			value = append(value, c)
-			cur.loc.col++
-			continue
-		}
-		break
-	}
 
-	if len(value) == 0 {
+	// E followed by a quote starts an escape string, even an invalid one
+	word := source[ic.pointer:pointer]
+	if (word == "E" || word == "e") && strings.HasPrefix(source[pointer:], "'") {
 		return nil, ic, false
 	}
 
-	return &token{
+	return &Token{
 		// Unquoted identifiers are case-insensitive
-		value: strings.ToLower(string(value)),
-		loc:   ic.loc,
-		kind:  identifierKind,
-	}, cur, true
+		Value: strings.ToLower(word),
+		Loc:   ic.loc,
+		Kind:  IdentifierKind,
+	}, ic.advance(source, pointer), true
+}
+
+// scanWord returns the end of the identifier-like word starting at
+// pointer, which is pointer itself when no word starts there.
+func scanWord(source string, pointer uint) uint {
+	r, size := utf8.DecodeRuneInString(source[pointer:])
+	if !isIdentifierStart(r) {
+		return pointer
+	}
+
+	for pointer < uint(len(source)) && isIdentifierPart(r) {
+		pointer += uint(size)
+		r, size = utf8.DecodeRuneInString(source[pointer:])
+	}
+
+	return pointer
+}
+
+func isIdentifierStart(r rune) bool {
+	return unicode.IsLetter(r)
+}
+
+func isIdentifierPart(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '$' || r == '_'
 }
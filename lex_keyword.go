@@ -4,106 +4,133 @@ import (
 	"strings"
 )
 
-func lexKeyword(source string, ic cursor) (*token, cursor, bool) {
-	cur := ic
+// keywordKinds maps every keyword to the kind of token it lexes as.
+var keywordKinds = map[keyword]TokenKind{
+	selectKeyword:     KeywordKind,
+	insertKeyword:     KeywordKind,
+	valuesKeyword:     KeywordKind,
+	tableKeyword:      KeywordKind,
+	createKeyword:     KeywordKind,
+	dropKeyword:       KeywordKind,
+	whereKeyword:      KeywordKind,
+	fromKeyword:       KeywordKind,
+	intoKeyword:       KeywordKind,
+	textKeyword:       KeywordKind,
+	boolKeyword:       KeywordKind,
+	intKeyword:        KeywordKind,
+	jsonKeyword:       KeywordKind,
+	andKeyword:        KeywordKind,
+	orKeyword:         KeywordKind,
+	asKeyword:         KeywordKind,
+	trueKeyword:       BoolKind,
+	falseKeyword:      BoolKind,
+	uniqueKeyword:     KeywordKind,
+	indexKeyword:      KeywordKind,
+	onKeyword:         KeywordKind,
+	primarykeyKeyword: KeywordKind,
+	nullKeyword:       NullKind,
+	castKeyword:       KeywordKind,
+	groupKeyword:      KeywordKind,
+	byKeyword:         KeywordKind,
+	overKeyword:       KeywordKind,
+	partitionKeyword:  KeywordKind,
+	orderKeyword:      KeywordKind,
+	ascKeyword:        KeywordKind,
+	descKeyword:       KeywordKind,
+	rowsKeyword:       KeywordKind,
+	rowKeyword:        KeywordKind,
+	betweenKeyword:    KeywordKind,
+	unboundedKeyword:  KeywordKind,
+	precedingKeyword:  KeywordKind,
+	followingKeyword:  KeywordKind,
+	currentKeyword:    KeywordKind,
+	caseKeyword:       KeywordKind,
+	whenKeyword:       KeywordKind,
+	thenKeyword:       KeywordKind,
+	elseKeyword:       KeywordKind,
+	endKeyword:        KeywordKind,
+	inKeyword:         KeywordKind,
+	likeKeyword:       KeywordKind,
+	ilikeKeyword:      KeywordKind,
+	escapeKeyword:     KeywordKind,
+	notKeyword:        KeywordKind,
+	distinctKeyword:   KeywordKind,
+	beginKeyword:      KeywordKind,
+	commitKeyword:     KeywordKind,
+	rollbackKeyword:   KeywordKind,
+	savepointKeyword:  KeywordKind,
+	releaseKeyword:    KeywordKind,
+	toKeyword:         KeywordKind,
+}
 
-	keywords := []keyword{
-		selectKeyword,
-		insertKeyword,
-		valuesKeyword,
-		tableKeyword,
-		createKeyword,
-		dropKeyword,
-		whereKeyword,
-		fromKeyword,
-		intoKeyword,
-		textKeyword,
-		boolKeyword,
-		intKeyword,
-		andKeyword,
-		orKeyword,
-		asKeyword,
-		trueKeyword,
-		falseKeyword,
-		uniqueKeyword,
-		indexKeyword,
-		onKeyword,
-		primarykeyKeyword,
-		nullKeyword,
-	}
+// nonReservedKeywords can also be used as identifiers wherever a keyword
+// isn't expected, like Postgres' unreserved keywords. Tables and columns
+// named after them kept working when they became keywords.
+var nonReservedKeywords = map[keyword]bool{
+	jsonKeyword:      true,
+	rowKeyword:       true,
+	rowsKeyword:      true,
+	toKeyword:        true,
+	partitionKeyword: true,
+	precedingKeyword: true,
+	followingKeyword: true,
+	escapeKeyword:    true,
+}
 
-	var options []string
-	for _, k := range keywords {
-		options = append(options, string(k))
-	}
+// keywordPhrases lists the keywords made of several words by their first
+// word, which is not a keyword by itself.
+var keywordPhrases = map[string][]string{
+	"primary": {"key"},
+}
 
-	match := longestMatch(source, ic, options)
-	if match == "" {
+// lexKeyword scans a whole word and looks it up among the keywords, so
+// that identifiers like selection or order_id are never split into a
+// keyword and a remainder.
+func lexKeyword(source string, ic cursor) (*Token, cursor, bool) {
+	end := scanWord(source, ic.pointer)
+	if end == ic.pointer {
 		return nil, ic, false
 	}
 
-	cur.pointer = ic.pointer + uint(len(match))
-	cur.loc.col = ic.loc.col + uint(len(match))
+	match := strings.ToLower(source[ic.pointer:end])
+	if rest, ok := keywordPhrases[match]; ok {
+		for _, word := range rest {
+			next := skipSpaces(source, end)
+			wordEnd := scanWord(source, next)
+			if next == end || !strings.EqualFold(source[next:wordEnd], word) {
+				return nil, ic, false
+			}
 
-	kind := keywordKind
-	if match == string(trueKeyword) || match == string(falseKeyword) {
-		kind = boolKind
+			match += " " + word
+			end = wordEnd
+		}
 	}
 
-	if match == string(nullKeyword) {
-		kind = nullKind
+	kind, ok := keywordKinds[keyword(match)]
+	if !ok {
+		return nil, ic, false
 	}
 
-	return &token{
-		value: match,
-		kind:  kind,
-		loc:   ic.loc,
-	}, cur, true
+	return &Token{
+		Value: match,
+		Kind:  kind,
+		Loc:   ic.loc,
+	}, ic.advance(source, end), true
 }
 
-// longestMatch iterates through a source string starting at the given
-// cursor to find the longest matching substring among the provided
-// options
-func longestMatch(source string, ic cursor, options []string) string {
-	var value []byte
-	var skipList []int
-	var match string
-
-	cur := ic
-	for cur.pointer < uint(len(source)) {
-		value = append(value, strings.ToLower(string(source[cur.pointer]))...)
-		cur.pointer++
-
-	match:
-		for i, option := range options {
-			for _, skip := range skipList {
-				if i == skip {
-					continue match
-				}
-			}
-
-			// Deal with cases like INT vs INTO
-			if option == string(value) {
-				skipList = append(skipList, i)
-				if len(option) > len(match) {
-					match = option
-				}
-				continue
-			}
-
-			sharesPrefix := string(value) == option[:cur.pointer-ic.pointer]
-			tooLong := len(value) > len(option)
-			if tooLong || !sharesPrefix {
-				skipList = append(skipList, i)
-			}
-		}
-
-		if len(skipList) == len(options) {
-			break
+// skipSpaces returns the position of the first byte at or after pointer
+// that is not whitespace.
+func skipSpaces(source string, pointer uint) uint {
+	for pointer < uint(len(source)) {
+		switch source[pointer] {
+		case ' ', '\t', '\n', '\r':
+			pointer++
+			continue
 		}
+		break
 	}
 
-	return match
+	return pointer
 }
 
 type keyword string
@@ -120,6 +147,7 @@ const (
 	valuesKeyword     keyword = "values"
 	intKeyword        keyword = "int"
 	textKeyword       keyword = "text"
+	jsonKeyword       keyword = "json"
 	boolKeyword       keyword = "boolean"
 	whereKeyword      keyword = "where"
 	andKeyword        keyword = "and"
@@ -131,4 +159,36 @@ const (
 	onKeyword         keyword = "on"
 	primarykeyKeyword keyword = "primary key"
 	nullKeyword       keyword = "null"
+	castKeyword       keyword = "cast"
+	groupKeyword      keyword = "group"
+	byKeyword         keyword = "by"
+	overKeyword       keyword = "over"
+	partitionKeyword  keyword = "partition"
+	orderKeyword      keyword = "order"
+	ascKeyword        keyword = "asc"
+	descKeyword       keyword = "desc"
+	rowsKeyword       keyword = "rows"
+	rowKeyword        keyword = "row"
+	betweenKeyword    keyword = "between"
+	unboundedKeyword  keyword = "unbounded"
+	precedingKeyword  keyword = "preceding"
+	followingKeyword  keyword = "following"
+	currentKeyword    keyword = "current"
+	caseKeyword       keyword = "case"
+	whenKeyword       keyword = "when"
+	thenKeyword       keyword = "then"
+	elseKeyword       keyword = "else"
+	endKeyword        keyword = "end"
+	inKeyword         keyword = "in"
+	likeKeyword       keyword = "like"
+	ilikeKeyword      keyword = "ilike"
+	escapeKeyword     keyword = "escape"
+	notKeyword        keyword = "not"
+	distinctKeyword   keyword = "distinct"
+	beginKeyword      keyword = "begin"
+	commitKeyword     keyword = "commit"
+	rollbackKeyword   keyword = "rollback"
+	savepointKeyword  keyword = "savepoint"
+	releaseKeyword    keyword = "release"
+	toKeyword         keyword = "to"
 )
@@ -1,21 +1,20 @@
 package gosql
 
-func lexNumeric(source string, ic cursor) (*token, cursor, bool) {
-	cur := ic
+func lexNumeric(source string, ic cursor) (*Token, cursor, bool) {
+	pointer := ic.pointer
 
 	periodFound := false
 	expMarkerFound := false
 
-	for ; cur.pointer < uint(len(source)); cur.pointer++ {
-		c := source[cur.pointer]
-		cur.loc.col++
+	for ; pointer < uint(len(source)); pointer++ {
+		c := source[pointer]
 
 		isDigit := c >= '0' && c <= '9'
 		isPeriod := c == '.'
 		isExpMarker := c == 'e'
 
 		// Must start with a digit or period
-		if cur.pointer == ic.pointer {
+		if pointer == ic.pointer {
 			if !isDigit && !isPeriod {
 				return nil, ic, false
 			}
@@ -43,14 +42,13 @@ func lexNumeric(source string, ic cursor) (*token, cursor, bool) {
 			expMarkerFound = true
 
 			// expMarket must be followed by digits
-			if cur.pointer == uint(len(source)-1) {
+			if pointer == uint(len(source)-1) {
 				return nil, ic, false
 			}
 
-			cNext := source[cur.pointer+1]
+			cNext := source[pointer+1]
 			if cNext == '-' || cNext == '+' {
-				cur.pointer++
-				cur.loc.col++
+				pointer++
 			}
 			continue
 		}
@@ -61,13 +59,13 @@ func lexNumeric(source string, ic cursor) (*token, cursor, bool) {
 	}
 
 	// No Characters accumulated
-	if cur.pointer == ic.pointer {
+	if pointer == ic.pointer {
 		return nil, ic, false
 	}
 
-	return &token{
-		value: source[ic.pointer:cur.pointer],
-		loc:   ic.loc,
-		kind:  numericKind,
-	}, cur, true
+	return &Token{
+		Value: source[ic.pointer:pointer],
+		Loc:   ic.loc,
+		Kind:  NumericKind,
+	}, ic.advance(source, pointer), true
 }
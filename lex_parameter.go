@@ -0,0 +1,38 @@
+package gosql
+
+// lexParameter lexes the $1, ? and :name bind parameter placeholders. The
+// token holds the placeholder as written; a :: cast is left to lexSymbol.
+func lexParameter(source string, ic cursor) (*Token, cursor, bool) {
+	pointer := ic.pointer + 1
+
+	switch source[ic.pointer] {
+	case '?':
+	case '$':
+		for pointer < uint(len(source)) && isDigit(source[pointer]) {
+			pointer++
+		}
+
+		if pointer == ic.pointer+1 {
+			return nil, ic, false
+		}
+	case ':':
+		end := scanWord(source, pointer)
+		if end == pointer {
+			return nil, ic, false
+		}
+
+		pointer = end
+	default:
+		return nil, ic, false
+	}
+
+	return &Token{
+		Value: source[ic.pointer:pointer],
+		Kind:  ParameterKind,
+		Loc:   ic.loc,
+	}, ic.advance(source, pointer), true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
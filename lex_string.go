@@ -1,46 +1,205 @@
 package gosql
 
-func lexCharacterDelimited(source string, ic cursor, delimiter byte) (*token, cursor, bool) {
-	cur := ic
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
 
-	if len(source[cur.pointer:]) == 0 {
+// lexFailure describes why a literal that was started couldn't be lexed
+// and the position of the source the mistake is at.
+type lexFailure struct {
+	pointer uint
+	msg     string
+}
+
+func lexCharacterDelimited(source string, ic cursor, delimiter byte) (*Token, cursor, bool) {
+	value, end, failure := scanQuoted(source, ic.pointer, ic.pointer, delimiter, false)
+	if end == ic.pointer || failure != nil {
 		return nil, ic, false
 	}
 
-	if source[cur.pointer] != delimiter {
+	return &Token{
+		Value: value,
+		Loc:   ic.loc,
+		Kind:  StringKind,
+	}, ic.advance(source, end), true
+}
+
+// lexString lexes a string literal: '...' where a doubled quote stands
+// for a single one, E'...' which also takes backslash escapes, or $$...$$
+// and $tag$...$tag$ whose contents are taken as is.
+func lexString(source string, ic cursor) (*Token, cursor, bool) {
+	value, end, failure := scanString(source, ic.pointer)
+	if end == ic.pointer || failure != nil {
 		return nil, ic, false
 	}
 
-	cur.loc.col++
-	cur.pointer++
+	return &Token{
+		Value: value,
+		Loc:   ic.loc,
+		Kind:  StringKind,
+	}, ic.advance(source, end), true
+}
+
+// scanString returns the value of the string literal starting at pointer
+// and the position past its end, which is pointer itself when no literal
+// starts there.
+func scanString(source string, pointer uint) (string, uint, *lexFailure) {
+	rest := source[pointer:]
+	switch {
+	case strings.HasPrefix(rest, "'"):
+		return scanQuoted(source, pointer, pointer, '\'', false)
+	case strings.HasPrefix(rest, "E'"), strings.HasPrefix(rest, "e'"):
+		return scanQuoted(source, pointer, pointer+1, '\'', true)
+	case strings.HasPrefix(rest, "$"):
+		return scanDollarQuoted(source, pointer)
+	}
+
+	return "", pointer, nil
+}
+
+// scanQuoted scans a literal starting at start whose contents are
+// enclosed in delimiters at open and wherever the closing one is. Doubled
+// delimiters stand for one and escapes enables backslash escapes.
+func scanQuoted(source string, start, open uint, delimiter byte, escapes bool) (string, uint, *lexFailure) {
+	if open >= uint(len(source)) || source[open] != delimiter {
+		return "", start, nil
+	}
 
 	var value []byte
-	for ; cur.pointer < uint(len(source)); cur.pointer++ {
-		c := source[cur.pointer]
-
-		if c == delimiter {
-			// SQL escapes are via double characters, not backslash
-			if cur.pointer+1 >= uint(len(source)) || source[cur.pointer+1] != delimiter {
-				cur.pointer++
-				cur.loc.col++
-				return &token{
-					value: string(value),
-					loc:   ic.loc,
-					kind:  stringKind,
-				}, cur, true
+	pointer := open + 1
+	for pointer < uint(len(source)) {
+		c := source[pointer]
+
+		switch {
+		case c == delimiter:
+			// SQL escapes quotes by doubling them
+			if pointer+1 >= uint(len(source)) || source[pointer+1] != delimiter {
+				return string(value), pointer + 1, nil
 			}
+
 			value = append(value, delimiter)
-			cur.pointer++
-			cur.loc.col++
+			pointer += 2
+		case c == '\\' && escapes:
+			escaped, length, failure := scanEscape(source, pointer)
+			if failure != nil {
+				return "", start, failure
+			}
+
+			value = append(value, escaped...)
+			pointer += length
+		default:
+			value = append(value, c)
+			pointer++
+		}
+	}
+
+	msg := "Unterminated string"
+	if delimiter == '"' {
+		msg = "Unterminated quoted identifier"
+	}
+	return "", start, &lexFailure{pointer: start, msg: msg}
+}
+
+// scanEscape decodes the backslash escape at pointer, returning its value
+// and the length of the escape. Like in Postgres, \b, \f, \n, \r and \t
+// stand for control characters, \ooo for an octal and \xhh for a
+// hexadecimal byte, \uxxxx and \Uxxxxxxxx for Unicode code points and a
+// backslash followed by any other character for that character.
+func scanEscape(source string, pointer uint) ([]byte, uint, *lexFailure) {
+	if pointer+1 >= uint(len(source)) {
+		// Let the caller report the literal as unterminated
+		return []byte{}, 1, nil
+	}
+
+	rest := source[pointer+1:]
+	switch c := rest[0]; c {
+	case 'b':
+		return []byte{'\b'}, 2, nil
+	case 'f':
+		return []byte{'\f'}, 2, nil
+	case 'n':
+		return []byte{'\n'}, 2, nil
+	case 'r':
+		return []byte{'\r'}, 2, nil
+	case 't':
+		return []byte{'\t'}, 2, nil
+	case 'x':
+		digits := countDigits(rest[1:], 2, 16)
+		if digits == 0 {
+			return []byte{'x'}, 2, nil
+		}
+
+		b, _ := strconv.ParseUint(rest[1:1+digits], 16, 8)
+		return []byte{byte(b)}, 2 + uint(digits), nil
+	case 'u', 'U':
+		digits := 4
+		if c == 'U' {
+			digits = 8
 		}
 
-		value = append(value, c)
-		cur.loc.col++
+		if countDigits(rest[1:], digits, 16) != digits {
+			return nil, 0, &lexFailure{pointer: pointer, msg: "Invalid Unicode escape"}
+		}
+
+		r, _ := strconv.ParseUint(rest[1:1+digits], 16, 32)
+		if !utf8.ValidRune(rune(r)) {
+			return nil, 0, &lexFailure{pointer: pointer, msg: "Invalid Unicode escape value"}
+		}
+
+		return []byte(string(rune(r))), 2 + uint(digits), nil
+	}
+
+	if digits := countDigits(rest, 3, 8); digits > 0 {
+		b, _ := strconv.ParseUint(rest[:digits], 8, 16)
+		return []byte{byte(b)}, 1 + uint(digits), nil
+	}
+
+	_, size := utf8.DecodeRuneInString(rest)
+	return []byte(rest[:size]), 1 + uint(size), nil
+}
+
+// countDigits returns how many of the first max bytes of s are digits in
+// the given base.
+func countDigits(s string, max, base int) int {
+	n := 0
+	for n < max && n < len(s) {
+		if _, err := strconv.ParseUint(s[n:n+1], base, 8); err != nil {
+			break
+		}
+		n++
 	}
 
-	return nil, ic, false
+	return n
 }
 
-func lexString(source string, ic cursor) (*token, cursor, bool) {
-	return lexCharacterDelimited(source, ic, '\'')
+// scanDollarQuoted scans a $tag$...$tag$ literal, where the tag may be
+// empty and is otherwise made of letters, digits and underscores without
+// starting with a digit.
+func scanDollarQuoted(source string, pointer uint) (string, uint, *lexFailure) {
+	tagEnd := pointer + 1
+	for tagEnd < uint(len(source)) {
+		r, size := utf8.DecodeRuneInString(source[tagEnd:])
+		isTag := isIdentifierStart(r) || r == '_' || (tagEnd > pointer+1 && isDigit(source[tagEnd]))
+		if !isTag {
+			break
+		}
+
+		tagEnd += uint(size)
+	}
+
+	if tagEnd >= uint(len(source)) || source[tagEnd] != '$' {
+		return "", pointer, nil
+	}
+
+	delimiter := source[pointer : tagEnd+1]
+	contents := tagEnd + 1
+	length := strings.Index(source[contents:], delimiter)
+	if length == -1 {
+		return "", pointer, &lexFailure{pointer: pointer, msg: "Unterminated dollar-quoted string"}
+	}
+
+	end := contents + uint(length)
+	return source[contents:end], end + uint(len(delimiter)), nil
 }
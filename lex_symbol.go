@@ -1,76 +1,78 @@
 package gosql
 
-func lexSymbol(source string, ic cursor) (*token, cursor, bool) {
-	c := source[ic.pointer]
-	cur := ic
-	cur.pointer++
-	cur.loc.col++
-
-	switch c {
+func lexSymbol(source string, ic cursor) (*Token, cursor, bool) {
+	switch source[ic.pointer] {
 	// Syntax that should be thrown away
-	case '\n':
-		cur.loc.line++
-		cur.loc.col = 0
-		fallthrough
-	case '\t':
-		fallthrough
-	case ' ':
-		return nil, cur, true
+	case '\n', '\r', '\t', ' ':
+		return nil, ic.advance(source, ic.pointer+1), true
 	}
 
-	symbols := []symbol{
-		eqSymbol,
-		neqSymbol,
-		neqSymbol2,
-		ltSymbol,
-		lteSymbol,
-		gtSymbol,
-		gteSymbol,
-		concatSymbol,
-		plusSymbol,
-		commaSymbol,
-		leftParenSymbol,
-		rightParenSymbol,
-		semicolonSymbol,
-		asteriskSymbol,
-	}
+	// Symbols are at most three bytes long, try the longest first
+	match := ""
+	for length := 3; length > 0; length-- {
+		end := ic.pointer + uint(length)
+		if end > uint(len(source)) {
+			continue
+		}
 
-	var options []string
-	for _, s := range symbols {
-		options = append(options, string(s))
+		if _, ok := symbols[symbol(source[ic.pointer:end])]; ok {
+			match = source[ic.pointer:end]
+			break
+		}
 	}
 
-	match := longestMatch(source, ic, options)
-
 	if match == "" {
 		return nil, ic, false
 	}
 
-	cur.pointer = ic.pointer + uint(len(match))
-	cur.loc.col = ic.loc.col + uint(len(match))
-
-	return &token{
-		value: match,
-		loc:   ic.loc,
-		kind:  symbolKind,
-	}, cur, true
+	return &Token{
+		Value: match,
+		Loc:   ic.loc,
+		Kind:  SymbolKind,
+	}, ic.advance(source, ic.pointer+uint(len(match))), true
 }
 
 type symbol string
 
+// symbols is the set of every symbol lexSymbol recognizes.
+var symbols = map[symbol]struct{}{
+	eqSymbol:          {},
+	neqSymbol:         {},
+	neqSymbol2:        {},
+	ltSymbol:          {},
+	lteSymbol:         {},
+	gtSymbol:          {},
+	gteSymbol:         {},
+	concatSymbol:      {},
+	plusSymbol:        {},
+	commaSymbol:       {},
+	leftParenSymbol:   {},
+	rightParenSymbol:  {},
+	semicolonSymbol:   {},
+	asteriskSymbol:    {},
+	arrowSymbol:       {},
+	doubleArrowSymbol: {},
+	castSymbol:        {},
+	tildeSymbol:       {},
+}
+
 const (
-	semicolonSymbol  symbol = ";"
-	asteriskSymbol   symbol = "*"
-	commaSymbol      symbol = ","
-	leftParenSymbol  symbol = "("
-	rightParenSymbol symbol = ")"
-	eqSymbol         symbol = "="
-	neqSymbol        symbol = "<>"
-	neqSymbol2       symbol = "!="
-	concatSymbol     symbol = "||"
-	plusSymbol       symbol = "+"
-	ltSymbol         symbol = "<"
-	lteSymbol        symbol = "<="
-	gtSymbol         symbol = ">"
-	gteSymbol        symbol = ">="
+	semicolonSymbol   symbol = ";"
+	asteriskSymbol    symbol = "*"
+	commaSymbol       symbol = ","
+	leftParenSymbol   symbol = "("
+	rightParenSymbol  symbol = ")"
+	eqSymbol          symbol = "="
+	neqSymbol         symbol = "<>"
+	neqSymbol2        symbol = "!="
+	concatSymbol      symbol = "||"
+	plusSymbol        symbol = "+"
+	ltSymbol          symbol = "<"
+	lteSymbol         symbol = "<="
+	gtSymbol          symbol = ">"
+	gteSymbol         symbol = ">="
+	arrowSymbol       symbol = "->"
+	doubleArrowSymbol symbol = "->>"
+	castSymbol        symbol = "::"
+	tildeSymbol       symbol = "~"
 )
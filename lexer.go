@@ -1,69 +1,140 @@
 package gosql
 
 import (
-	"fmt"
+	"strings"
+	"unicode/utf8"
 )
 
-type location struct {
-	line uint
-	col  uint
+// Location is where a token starts: its zero-based line, its zero-based
+// column counted in runes and its byte offset into the source.
+type Location struct {
+	Line   uint
+	Col    uint
+	Offset uint
 }
 
-type tokenKind uint
+// TokenKind classifies a token.
+type TokenKind uint
 
 const (
-	keywordKind tokenKind = iota
-	symbolKind
-	identifierKind
-	stringKind
-	numericKind
-	boolKind
-	nullKind
+	KeywordKind TokenKind = iota
+	SymbolKind
+	IdentifierKind
+	StringKind
+	NumericKind
+	BoolKind
+	NullKind
+	CommentKind
+	ParameterKind
 )
 
 type cursor struct {
 	pointer uint
-	loc     location
+	loc     Location
 }
 
-type token struct {
-	value string
-	kind  tokenKind
-	loc   location
+// advance moves the cursor forward to the byte at end, counting the lines
+// and runes passed on the way. \n, \r\n and a lone \r each end a line.
+func (c cursor) advance(source string, end uint) cursor {
+	// The offset is kept apart from the pointer so that a source can be
+	// lexed in pieces, starting each at the location the last one ended
+	c.loc.Offset += end - c.pointer
+	for c.pointer < end {
+		b := source[c.pointer]
+		c.pointer++
+
+		switch {
+		case b == '\r' && c.pointer < uint(len(source)) && source[c.pointer] == '\n':
+			// The \n that follows ends the line
+		case b == '\n' || b == '\r':
+			c.loc.Line++
+			c.loc.Col = 0
+		case utf8.RuneStart(b):
+			c.loc.Col++
+		}
+	}
+
+	return c
 }
 
-func (t *token) equals(other *token) bool {
-	return t.value == other.value && t.kind == other.kind
+// Token is a lexed piece of source. Value holds the text of identifiers,
+// such as table and column names, and the contents of string literals.
+type Token struct {
+	Value string
+	Kind  TokenKind
+	Loc   Location
 }
 
-type lexer func(string, cursor) (*token, cursor, bool)
+func (t *Token) equals(other *Token) bool {
+	return t.Value == other.Value && t.Kind == other.Kind
+}
 
-func lex(source string) ([]*token, error) {
-	var tokens []*token
-	cur := cursor{}
+type lexer func(string, cursor) (*Token, cursor, bool)
 
-lex:
-	for cur.pointer < uint(len(source)) {
-		lexers := []lexer{lexKeyword, lexSymbol, lexString, lexNumeric, lexIdentifier}
-		for _, l := range lexers {
-			if token, newCursor, ok := l(source, cur); ok {
-				cur = newCursor
+var lexers = []lexer{lexComment, lexParameter, lexKeyword, lexSymbol, lexString, lexNumeric, lexIdentifier}
 
-				// Omit nil tokens for valid but empty syntax like newlines
-				if token != nil {
-					tokens = append(tokens, token)
-				}
+func lex(source string) ([]*Token, error) {
+	return Lex(source, false)
+}
+
+// Lex splits a source into tokens. Comments are dropped unless
+// keepComments is set, in which case they are kept as CommentKind tokens
+// so that tools like formatters can preserve them.
+func Lex(source string, keepComments bool) ([]*Token, error) {
+	var tokens []*Token
+	cur := cursor{}
 
-				continue lex
+	for cur.pointer < uint(len(source)) {
+		token, newCursor, ok := lexToken(source, cur)
+		if !ok {
+			var previous *Token
+			if len(tokens) > 0 {
+				previous = tokens[len(tokens)-1]
 			}
+
+			return nil, lexError(source, cur, previous)
 		}
+		cur = newCursor
 
-		hint := ""
-		if len(tokens) > 0 {
-			hint = "after " + tokens[len(tokens)-1].value
+		// Omit nil tokens for valid but empty syntax like newlines
+		if token != nil && (token.Kind != CommentKind || keepComments) {
+			tokens = append(tokens, token)
 		}
-		return nil, fmt.Errorf("unable to lex token %s, at %d:%d", hint, cur.loc.line, cur.loc.col)
 	}
 
 	return tokens, nil
 }
+
+// lexToken lexes the token at the cursor with the first lexer that
+// accepts it.
+func lexToken(source string, cur cursor) (*Token, cursor, bool) {
+	for _, l := range lexers {
+		if token, newCursor, ok := l(source, cur); ok {
+			return token, newCursor, true
+		}
+	}
+
+	return nil, cur, false
+}
+
+// lexError describes why no lexer accepted the source at the cursor.
+func lexError(source string, cur cursor, previous *Token) *ParseError {
+	// Report literals that were started at the mistake found in them
+	_, _, failure := scanString(source, cur.pointer)
+	if failure == nil {
+		_, _, failure = scanQuoted(source, cur.pointer, cur.pointer, '"', false)
+	}
+	if failure != nil {
+		return &ParseError{Loc: cur.advance(source, failure.pointer).loc, Msg: failure.msg}
+	}
+
+	if strings.HasPrefix(source[cur.pointer:], "/*") {
+		return &ParseError{Loc: cur.loc, Msg: "Unterminated block comment"}
+	}
+
+	msg := "Unable to lex token"
+	if previous != nil {
+		msg += " after " + previous.Value
+	}
+	return &ParseError{Loc: cur.loc, Msg: msg}
+}
@@ -83,7 +83,7 @@ func TestToken_lexNumeric(t *testing.T) {
 		tok, _, ok := lexNumeric(test.value, cursor{})
 		assert.Equal(t, test.number, ok, test.value)
 		if ok {
-			assert.Equal(t, strings.TrimSpace(test.value), tok.value, test.value)
+			assert.Equal(t, strings.TrimSpace(test.value), tok.Value, test.value)
 		}
 	}
 }
@@ -91,53 +91,136 @@ func TestToken_lexNumeric(t *testing.T) {
 func TestToken_lexString(t *testing.T) {
 	tests := []struct {
 		string bool
+		input  string
 		value  string
 	}{
 		{
 			string: false,
+			input:  "a",
+		},
+		{
+			string: true,
+			input:  "'abc'",
+			value:  "abc",
+		},
+		{
+			string: true,
+			input:  "'a b'",
+			value:  "a b",
+		},
+		{
+			string: true,
+			input:  "'a' ",
 			value:  "a",
 		},
 		{
 			string: true,
-			value:  "'abc'",
+			input:  "'a '' b'",
+			value:  "a ' b",
 		},
 		{
 			string: true,
-			value:  "'a b'",
+			input:  `'a\n'`,
+			value:  `a\n`,
 		},
 		{
 			string: true,
-			value:  "'a' ",
+			input:  `E'a\n\tb\\'`,
+			value:  "a\n\tb\\",
 		},
 		{
 			string: true,
-			value:  "'a '' b'",
+			input:  `e'it\'s ''quoted'''`,
+			value:  "it's 'quoted'",
+		},
+		{
+			string: true,
+			input:  `E'\101\x42é\U0001F600\q'`,
+			value:  "ABé😀q",
+		},
+		{
+			string: true,
+			input:  `$$it's {"a": 1}$$`,
+			value:  `it's {"a": 1}`,
+		},
+		{
+			string: true,
+			input:  "$fn$ SELECT $$x$$; $fn$",
+			value:  " SELECT $$x$$; ",
 		},
 		// false tests
 		{
 			string: false,
-			value:  "'",
+			input:  "'",
+		},
+		{
+			string: false,
+			input:  "",
+		},
+		{
+			string: false,
+			input:  " 'foo'",
+		},
+		{
+			string: false,
+			input:  `E'\'`,
+		},
+		{
+			string: false,
+			input:  `E'\u12'`,
 		},
 		{
 			string: false,
-			value:  "",
+			input:  "$1",
 		},
 		{
 			string: false,
-			value:  " 'foo'",
+			input:  "$a$ unterminated $b$",
 		},
 	}
 
 	for _, test := range tests {
-		tok, _, ok := lexString(test.value, cursor{})
-		assert.Equal(t, test.string, ok, test.value)
+		tok, _, ok := lexString(test.input, cursor{})
+		assert.Equal(t, test.string, ok, test.input)
 		if ok {
-			test.value = strings.TrimSpace(test.value)
-			assert.Equal(t, test.value[1:len(test.value)-1], tok.value, test.value)
+			assert.Equal(t, test.value, tok.Value, test.input)
 		}
 	}
 }
 
+func TestLex_LiteralErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   *ParseError
+	}{
+		{
+			input: "SELECT 'a',\n  'unterminated",
+			err:   &ParseError{Loc: Location{Line: 1, Col: 2, Offset: 14}, Msg: "Unterminated string"},
+		},
+		{
+			input: `SELECT E'é\u00zz'`,
+			err:   &ParseError{Loc: Location{Line: 0, Col: 10, Offset: 11}, Msg: "Invalid Unicode escape"},
+		},
+		{
+			input: `SELECT E'\UFFFFFFFF'`,
+			err:   &ParseError{Loc: Location{Line: 0, Col: 9, Offset: 9}, Msg: "Invalid Unicode escape value"},
+		},
+		{
+			input: "SELECT 1; SELECT $body$ a;\n b;",
+			err:   &ParseError{Loc: Location{Line: 0, Col: 17, Offset: 17}, Msg: "Unterminated dollar-quoted string"},
+		},
+		{
+			input: `SELECT "name`,
+			err:   &ParseError{Loc: Location{Line: 0, Col: 7, Offset: 7}, Msg: "Unterminated quoted identifier"},
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Lex(test.input, false)
+		assert.Equal(t, test.err, err, test.input)
+	}
+}
+
 func TestToken_lexSymbol(t *testing.T) {
 	tests := []struct {
 		symbol bool
@@ -151,6 +234,10 @@ func TestToken_lexSymbol(t *testing.T) {
 			symbol: true,
 			value:  "||",
 		},
+		{
+			symbol: true,
+			value:  "->>",
+		},
 	}
 
 	for _, test := range tests {
@@ -158,7 +245,7 @@ func TestToken_lexSymbol(t *testing.T) {
 		assert.Equal(t, test.symbol, ok, test.value)
 		if ok {
 			test.value = strings.TrimSpace(test.value)
-			assert.Equal(t, test.value, tok.value, test.value)
+			assert.Equal(t, test.value, tok.Value, test.value)
 		}
 	}
 }
@@ -209,6 +296,16 @@ func TestToken_lexIdentifier(t *testing.T) {
 			identifier: false,
 			input:      `"`,
 		},
+		{
+			identifier: true,
+			input:      "Café_1 ",
+			value:      "café_1",
+		},
+		{
+			identifier: true,
+			input:      "名前,",
+			value:      "名前",
+		},
 		{
 			identifier: false,
 			input:      "_sadsfa",
@@ -227,7 +324,7 @@ func TestToken_lexIdentifier(t *testing.T) {
 		tok, _, ok := lexIdentifier(test.input, cursor{})
 		assert.Equal(t, test.identifier, ok, test.input)
 		if ok {
-			assert.Equal(t, test.value, tok.value, test.input)
+			assert.Equal(t, test.value, tok.Value, test.input)
 		}
 	}
 }
@@ -257,243 +354,435 @@ func TestToken_lexKeyword(t *testing.T) {
 			keyword: true,
 			value:   "into",
 		},
+		{
+			keyword: true,
+			value:   "Primary Key(",
+		},
 		// false tests
 		{
 			keyword: false,
 			value:   " into",
 		},
+		{
+			keyword: false,
+			value:   "selection",
+		},
+		{
+			keyword: false,
+			value:   "into_x",
+		},
+		{
+			keyword: false,
+			value:   "order_id",
+		},
+		{
+			keyword: false,
+			value:   "primary keys",
+		},
 		{
 			keyword: false,
 			value:   "flubbrety",
 		},
+		{
+			keyword: false,
+			value:   "json_extract",
+		},
 	}
 
 	for _, test := range tests {
 		tok, _, ok := lexKeyword(test.value, cursor{})
 		assert.Equal(t, test.keyword, ok, test.value)
 		if ok {
-			test.value = strings.TrimSpace(test.value)
-			assert.Equal(t, strings.ToLower(test.value), tok.value, test.value)
+			test.value = strings.TrimRight(test.value, " (")
+			assert.Equal(t, strings.ToLower(test.value), tok.Value, test.value)
 		}
 	}
 }
 
+func TestToken_lexComment(t *testing.T) {
+	tests := []struct {
+		comment bool
+		input   string
+		value   string
+		end     Location
+	}{
+		{
+			comment: true,
+			input:   "-- a comment\nselect",
+			value:   "-- a comment",
+			end:     Location{Line: 0, Col: 12, Offset: 12},
+		},
+		{
+			comment: true,
+			input:   "--",
+			value:   "--",
+			end:     Location{Line: 0, Col: 2, Offset: 2},
+		},
+		{
+			comment: true,
+			input:   "/* a\n /* nested */\n */ select",
+			value:   "/* a\n /* nested */\n */",
+			end:     Location{Line: 2, Col: 3, Offset: 22},
+		},
+		// false tests
+		{
+			comment: false,
+			input:   "-",
+		},
+		{
+			comment: false,
+			input:   "/* /* unterminated */",
+		},
+		{
+			comment: false,
+			input:   " -- a",
+		},
+	}
+
+	for _, test := range tests {
+		tok, cur, ok := lexComment(test.input, cursor{})
+		assert.Equal(t, test.comment, ok, test.input)
+		if ok {
+			assert.Equal(t, test.value, tok.Value, test.input)
+			assert.Equal(t, CommentKind, tok.Kind, test.input)
+			assert.Equal(t, test.end, cur.loc, test.input)
+		}
+	}
+}
+
+func TestToken_lexParameter(t *testing.T) {
+	tests := []struct {
+		parameter bool
+		input     string
+		value     string
+	}{
+		{
+			parameter: true,
+			input:     "$1",
+			value:     "$1",
+		},
+		{
+			parameter: true,
+			input:     "$12)",
+			value:     "$12",
+		},
+		{
+			parameter: true,
+			input:     "?,",
+			value:     "?",
+		},
+		{
+			parameter: true,
+			input:     ":user_id2 ",
+			value:     ":user_id2",
+		},
+		// false tests
+		{
+			parameter: false,
+			input:     "$",
+		},
+		{
+			parameter: false,
+			input:     "$a",
+		},
+		{
+			parameter: false,
+			input:     "::int",
+		},
+		{
+			parameter: false,
+			input:     ": name",
+		},
+	}
+
+	for _, test := range tests {
+		tok, cur, ok := lexParameter(test.input, cursor{})
+		assert.Equal(t, test.parameter, ok, test.input)
+		if ok {
+			assert.Equal(t, test.value, tok.Value, test.input)
+			assert.Equal(t, ParameterKind, tok.Kind, test.input)
+			assert.Equal(t, uint(len(test.value)), cur.loc.Col, test.input)
+		}
+	}
+}
+
+func TestLex_Comments(t *testing.T) {
+	source := "-- users\nselect /* all */ a\n  /* the\ntable */ from t -- done"
+
+	tokens, err := Lex(source, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Token{
+		{Loc: Location{Line: 1, Col: 0, Offset: 9}, Value: "select", Kind: KeywordKind},
+		{Loc: Location{Line: 1, Col: 17, Offset: 26}, Value: "a", Kind: IdentifierKind},
+		{Loc: Location{Line: 3, Col: 9, Offset: 46}, Value: "from", Kind: KeywordKind},
+		{Loc: Location{Line: 3, Col: 14, Offset: 51}, Value: "t", Kind: IdentifierKind},
+	}, tokens)
+
+	tokens, err = Lex(source, true)
+	assert.Nil(t, err)
+	var comments []Token
+	for _, tok := range tokens {
+		if tok.Kind == CommentKind {
+			comments = append(comments, *tok)
+		}
+	}
+	assert.Equal(t, []Token{
+		{Loc: Location{Line: 0, Col: 0}, Value: "-- users", Kind: CommentKind},
+		{Loc: Location{Line: 1, Col: 7, Offset: 16}, Value: "/* all */", Kind: CommentKind},
+		{Loc: Location{Line: 2, Col: 2, Offset: 30}, Value: "/* the\ntable */", Kind: CommentKind},
+		{Loc: Location{Line: 3, Col: 16, Offset: 53}, Value: "-- done", Kind: CommentKind},
+	}, comments)
+
+	_, err = Lex("select /* a", false)
+	assert.Equal(t, &ParseError{Loc: Location{Line: 0, Col: 7, Offset: 7}, Msg: "Unterminated block comment"}, err)
+}
+
 func TestLex(t *testing.T) {
 	tests := []struct {
 		input  string
-		tokens []token
+		tokens []Token
 		err    error
 	}{
 		{
 			input: "select a",
-			tokens: []token{
+			tokens: []Token{
 				{
-					loc:   location{col: 0, line: 0},
-					value: string(selectKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 0, Line: 0, Offset: 0},
+					Value: string(selectKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
-					value: "a",
-					kind:  identifierKind,
+					Loc:   Location{Col: 7, Line: 0, Offset: 7},
+					Value: "a",
+					Kind:  IdentifierKind,
 				},
 			},
 		},
 		{
 			input: "select true",
-			tokens: []token{
+			tokens: []Token{
 				{
-					loc:   location{col: 0, line: 0},
-					value: string(selectKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 0, Line: 0, Offset: 0},
+					Value: string(selectKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
-					value: "true",
-					kind:  boolKind,
+					Loc:   Location{Col: 7, Line: 0, Offset: 7},
+					Value: "true",
+					Kind:  BoolKind,
+				},
+			},
+		},
+		{
+			input: "a = :a::int",
+			tokens: []Token{
+				{
+					Loc:   Location{Col: 0, Line: 0, Offset: 0},
+					Value: "a",
+					Kind:  IdentifierKind,
+				},
+				{
+					Loc:   Location{Col: 2, Line: 0, Offset: 2},
+					Value: "=",
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 4, Line: 0, Offset: 4},
+					Value: ":a",
+					Kind:  ParameterKind,
+				},
+				{
+					Loc:   Location{Col: 6, Line: 0, Offset: 6},
+					Value: "::",
+					Kind:  SymbolKind,
+				},
+				{
+					Loc:   Location{Col: 8, Line: 0, Offset: 8},
+					Value: "int",
+					Kind:  KeywordKind,
 				},
 			},
 		},
 		{
 			input: "select 1",
-			tokens: []token{
+			tokens: []Token{
 				{
-					loc:   location{col: 0, line: 0},
-					value: string(selectKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 0, Line: 0, Offset: 0},
+					Value: string(selectKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
-					value: "1",
-					kind:  numericKind,
+					Loc:   Location{Col: 7, Line: 0, Offset: 7},
+					Value: "1",
+					Kind:  NumericKind,
 				},
 			},
 			err: nil,
 		},
 		{
 			input: "select 'foo' || 'bar';",
-			tokens: []token{
+			tokens: []Token{
 				{
-					loc:   location{col: 0, line: 0},
-					value: string(selectKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 0, Line: 0, Offset: 0},
+					Value: string(selectKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
-					value: "foo",
-					kind:  stringKind,
+					Loc:   Location{Col: 7, Line: 0, Offset: 7},
+					Value: "foo",
+					Kind:  StringKind,
 				},
 				{
-					loc:   location{col: 13, line: 0},
-					value: string(concatSymbol),
-					kind:  symbolKind,
+					Loc:   Location{Col: 13, Line: 0, Offset: 13},
+					Value: string(concatSymbol),
+					Kind:  SymbolKind,
 				},
 				{
-					loc:   location{col: 16, line: 0},
-					value: "bar",
-					kind:  stringKind,
+					Loc:   Location{Col: 16, Line: 0, Offset: 16},
+					Value: "bar",
+					Kind:  StringKind,
 				},
 				{
-					loc:   location{col: 21, line: 0},
-					value: string(semicolonSymbol),
-					kind:  symbolKind,
+					Loc:   Location{Col: 21, Line: 0, Offset: 21},
+					Value: string(semicolonSymbol),
+					Kind:  SymbolKind,
 				},
 			},
 			err: nil,
 		},
 		{
 			input: "CREATE TABLE u (id INT, name TEXT)",
-			tokens: []token{
+			tokens: []Token{
 				{
-					loc:   location{col: 0, line: 0},
-					value: string(createKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 0, Line: 0, Offset: 0},
+					Value: string(createKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
-					value: string(tableKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 7, Line: 0, Offset: 7},
+					Value: string(tableKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 13, line: 0},
-					value: "u",
-					kind:  identifierKind,
+					Loc:   Location{Col: 13, Line: 0, Offset: 13},
+					Value: "u",
+					Kind:  IdentifierKind,
 				},
 				{
-					loc:   location{col: 15, line: 0},
-					value: "(",
-					kind:  symbolKind,
+					Loc:   Location{Col: 15, Line: 0, Offset: 15},
+					Value: "(",
+					Kind:  SymbolKind,
 				},
 				{
-					loc:   location{col: 16, line: 0},
-					value: "id",
-					kind:  identifierKind,
+					Loc:   Location{Col: 16, Line: 0, Offset: 16},
+					Value: "id",
+					Kind:  IdentifierKind,
 				},
 				{
-					loc:   location{col: 19, line: 0},
-					value: "int",
-					kind:  keywordKind,
+					Loc:   Location{Col: 19, Line: 0, Offset: 19},
+					Value: "int",
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 22, line: 0},
-					value: ",",
-					kind:  symbolKind,
+					Loc:   Location{Col: 22, Line: 0, Offset: 22},
+					Value: ",",
+					Kind:  SymbolKind,
 				},
 				{
-					loc:   location{col: 24, line: 0},
-					value: "name",
-					kind:  identifierKind,
+					Loc:   Location{Col: 24, Line: 0, Offset: 24},
+					Value: "name",
+					Kind:  IdentifierKind,
 				},
 				{
-					loc:   location{col: 29, line: 0},
-					value: "text",
-					kind:  keywordKind,
+					Loc:   Location{Col: 29, Line: 0, Offset: 29},
+					Value: "text",
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 33, line: 0},
-					value: ")",
-					kind:  symbolKind,
+					Loc:   Location{Col: 33, Line: 0, Offset: 33},
+					Value: ")",
+					Kind:  SymbolKind,
 				},
 			},
 		},
 		{
 			input: "insert into users values (105, 233)",
-			tokens: []token{
+			tokens: []Token{
 				{
-					loc:   location{col: 0, line: 0},
-					value: string(insertKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 0, Line: 0, Offset: 0},
+					Value: string(insertKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
-					value: string(intoKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 7, Line: 0, Offset: 7},
+					Value: string(intoKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 12, line: 0},
-					value: "users",
-					kind:  identifierKind,
+					Loc:   Location{Col: 12, Line: 0, Offset: 12},
+					Value: "users",
+					Kind:  IdentifierKind,
 				},
 				{
-					loc:   location{col: 18, line: 0},
-					value: string(valuesKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 18, Line: 0, Offset: 18},
+					Value: string(valuesKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 25, line: 0},
-					value: "(",
-					kind:  symbolKind,
+					Loc:   Location{Col: 25, Line: 0, Offset: 25},
+					Value: "(",
+					Kind:  SymbolKind,
 				},
 				{
-					loc:   location{col: 26, line: 0},
-					value: "105",
-					kind:  numericKind,
+					Loc:   Location{Col: 26, Line: 0, Offset: 26},
+					Value: "105",
+					Kind:  NumericKind,
 				},
 				{
-					loc:   location{col: 30, line: 0},
-					value: ",",
-					kind:  symbolKind,
+					Loc:   Location{Col: 29, Line: 0, Offset: 29},
+					Value: ",",
+					Kind:  SymbolKind,
 				},
 				{
-					loc:   location{col: 32, line: 0},
-					value: "233",
-					kind:  numericKind,
+					Loc:   Location{Col: 31, Line: 0, Offset: 31},
+					Value: "233",
+					Kind:  NumericKind,
 				},
 				{
-					loc:   location{col: 36, line: 0},
-					value: ")",
-					kind:  symbolKind,
+					Loc:   Location{Col: 34, Line: 0, Offset: 34},
+					Value: ")",
+					Kind:  SymbolKind,
 				},
 			},
 			err: nil,
 		},
 		{
 			input: "SELECT id FROM users;",
-			tokens: []token{
+			tokens: []Token{
 				{
-					loc:   location{col: 0, line: 0},
-					value: string(selectKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 0, Line: 0, Offset: 0},
+					Value: string(selectKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 7, line: 0},
-					value: "id",
-					kind:  identifierKind,
+					Loc:   Location{Col: 7, Line: 0, Offset: 7},
+					Value: "id",
+					Kind:  IdentifierKind,
 				},
 				{
-					loc:   location{col: 10, line: 0},
-					value: string(fromKeyword),
-					kind:  keywordKind,
+					Loc:   Location{Col: 10, Line: 0, Offset: 10},
+					Value: string(fromKeyword),
+					Kind:  KeywordKind,
 				},
 				{
-					loc:   location{col: 15, line: 0},
-					value: "users",
-					kind:  identifierKind,
+					Loc:   Location{Col: 15, Line: 0, Offset: 15},
+					Value: "users",
+					Kind:  IdentifierKind,
 				},
 				{
-					loc:   location{col: 20, line: 0},
-					value: ";",
-					kind:  symbolKind,
+					Loc:   Location{Col: 20, Line: 0, Offset: 20},
+					Value: ";",
+					Kind:  SymbolKind,
 				},
 			},
 			err: nil,
@@ -510,3 +799,34 @@ func TestLex(t *testing.T) {
 		}
 	}
 }
+
+func TestLex_Positions(t *testing.T) {
+	source := "SELECT 12,\r\n  'multi\nline', né\r\nFROM \"ünï\"; -- ✓\rSELECT $1"
+
+	tokens, err := Lex(source, false)
+	assert.Nil(t, err)
+
+	var locations []Location
+	for _, tok := range tokens {
+		locations = append(locations, tok.Loc)
+	}
+	assert.Equal(t, []Location{
+		{Line: 0, Col: 0, Offset: 0},   // SELECT
+		{Line: 0, Col: 7, Offset: 7},   // 12
+		{Line: 0, Col: 9, Offset: 9},   // ,
+		{Line: 1, Col: 2, Offset: 14},  // 'multi\nline'
+		{Line: 2, Col: 5, Offset: 26},  // ,
+		{Line: 2, Col: 7, Offset: 28},  // né
+		{Line: 3, Col: 0, Offset: 33},  // FROM
+		{Line: 3, Col: 5, Offset: 38},  // "ünï"
+		{Line: 3, Col: 10, Offset: 45}, // ;
+		{Line: 4, Col: 0, Offset: 54},  // SELECT
+		{Line: 4, Col: 7, Offset: 61},  // $1
+	}, locations)
+
+	_, err = Parse("SELECT a,\r\n  é FROM\r\n  t t")
+	assert.Equal(t, Location{Line: 2, Col: 4, Offset: 26}, err.(ParseErrors)[0].Loc)
+
+	_, err = Lex("SELECT 'ü',\n  §", false)
+	assert.Equal(t, &ParseError{Loc: Location{Line: 1, Col: 2, Offset: 15}, Msg: "Unable to lex token after ,"}, err)
+}
@@ -3,9 +3,13 @@ package gosql
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 type ColumnType uint
@@ -13,11 +17,40 @@ type ColumnType uint
 const (
 	TextType ColumnType = iota
 	IntType
+	JSONType
+	NullType
+	BoolType
 )
 
+func (c ColumnType) String() string {
+	switch c {
+	case TextType:
+		return "text"
+	case IntType:
+		return "int"
+	case JSONType:
+		return "json"
+	case NullType:
+		return "null"
+	case BoolType:
+		return "boolean"
+	}
+
+	return "unknown"
+}
+
 type Cell interface {
 	AsText() string
-	AsInt() int32
+	AsInt() (int32, error)
+	IsNull() bool
+}
+
+// BoolCell is a Cell that can be read as a boolean, which the cells of
+// BoolType columns are. It is separate from Cell so that implementing Cell
+// doesn't require AsBool.
+type BoolCell interface {
+	Cell
+	AsBool() (bool, error)
 }
 
 type Results struct {
@@ -34,6 +67,28 @@ var (
 	ErrInvalidSelectItem  = errors.New("Select item is not valid")
 	ErrInvalidDatatype    = errors.New("Invalid datatype")
 	ErrMissingValues      = errors.New("Missing values")
+	ErrInvalidJSON        = errors.New("Invalid JSON")
+	ErrInvalidJSONPath    = errors.New("Invalid JSON path")
+	ErrInvalidOperands    = errors.New("Invalid operands")
+	ErrInvalidCell        = errors.New("Cell is invalid")
+	ErrFunctionNotFound   = errors.New("Function does not exist")
+	ErrInvalidArguments   = errors.New("Invalid function arguments")
+	ErrInvalidInteger     = errors.New("Invalid integer")
+	ErrTypeMismatch       = errors.New("Type mismatch")
+	ErrInvalidCast        = errors.New("Invalid cast")
+	ErrDivisionByZero     = errors.New("Division by zero")
+	ErrFunctionExists     = errors.New("Function already exists")
+	ErrMisplacedAggregate = errors.New("Aggregate functions are not allowed here")
+	ErrColumnNotGrouped   = errors.New("Column must appear in GROUP BY or be used in an aggregate")
+
+	ErrInvalidWindowFunction = errors.New("Function is not a window function")
+	ErrWindowRequiresOver    = errors.New("Window function requires an OVER clause")
+	ErrInvalidWindowFrame    = errors.New("Invalid window frame")
+
+	ErrInvalidPattern = errors.New("Invalid pattern")
+
+	ErrInvalidOrderBy  = errors.New("Invalid ORDER BY")
+	ErrInvalidDistinct = errors.New("Invalid DISTINCT")
 )
 
 type BackEnd interface {
@@ -44,201 +99,1113 @@ type BackEnd interface {
 
 type MemoryCell []byte
 
-func (mc MemoryCell) AsInt() int32 {
+func (mc MemoryCell) AsInt() (int32, error) {
+	if len(mc) != 4 {
+		return 0, ErrInvalidCell
+	}
+
 	var i int32
 	if err := binary.Read(bytes.NewBuffer(mc), binary.BigEndian, &i); err != nil {
-		panic(err)
+		return 0, err
 	}
 
-	return i
+	return i, nil
+}
+
+func (mc MemoryCell) AsBool() (bool, error) {
+	if len(mc) != 1 {
+		return false, ErrInvalidCell
+	}
+
+	return mc[0] == 1, nil
 }
 
 func (mc MemoryCell) AsText() string {
 	return string(mc)
 }
 
+// IsNull reports whether the cell holds SQL NULL, which is represented by
+// a nil MemoryCell as opposed to an empty one.
+func (mc MemoryCell) IsNull() bool {
+	return mc == nil
+}
+
+// table is a version of a table, created by the transaction with ID xmin
+// and deleted by the one with ID xmax like its rowVersions. Statements
+// are evaluated against tables holding the rows they can see.
 type table struct {
 	columns     []string
 	columnTypes []ColumnType
 	rows        [][]MemoryCell
+	versions    []*rowVersion
+	xmin        uint64
+	xmax        uint64
+	indexes     []*index
+	cache       *exprCache
 }
 
+// exprCache holds what is worked out about expressions evaluated against a
+// table besides their values, so that it's done for the first row of a
+// statement rather than for every row: the types of CASE expressions and
+// predicates once checked, the matchers of constant patterns and the
+// functions calls refer to.
+type exprCache struct {
+	types     map[*Expression]ColumnType
+	matchers  map[*Expression]matcher
+	functions map[*Expression]function
+}
+
+// exprCache returns the cache of the table, creating it on first use.
+func (t *table) exprCache() *exprCache {
+	if t.cache == nil {
+		t.cache = &exprCache{
+			types:     map[*Expression]ColumnType{},
+			matchers:  map[*Expression]matcher{},
+			functions: map[*Expression]function{},
+		}
+	}
+
+	return t.cache
+}
+
+// checkedType returns the type check works out for exp, only running it
+// the first time exp is evaluated against the table.
+func (t *table) checkedType(exp *Expression, check func() (ColumnType, error)) (ColumnType, error) {
+	cache := t.exprCache()
+	if typ, ok := cache.types[exp]; ok {
+		return typ, nil
+	}
+
+	typ, err := check()
+	if err != nil {
+		return 0, err
+	}
+
+	cache.types[exp] = typ
+	return typ, nil
+}
+
+// calledFunction returns the scalar function a call refers to, only
+// looking it up the first time the call is evaluated against the table.
+func (mb *MemoryBackend) calledFunction(t *table, exp *Expression) (function, bool) {
+	cache := t.exprCache()
+	if fn, ok := cache.functions[exp]; ok {
+		return fn, true
+	}
+
+	fn, ok := mb.lookupFunction(exp.Call.Name.Value)
+	if ok {
+		cache.functions[exp] = fn
+	}
+
+	return fn, ok
+}
+
+// MemoryBackend is a BackEnd keeping its tables in memory. It is safe for
+// concurrent use: every statement runs in a transaction that sees a
+// snapshot of the tables, so readers don't block each other and only wait
+// for writers while the rows they see are gathered.
 type MemoryBackend struct {
-	tables map[string]*table
+	// mu guards everything below along with the versions of every table
+	mu sync.RWMutex
+	// tables holds the versions of each table, oldest first
+	tables     map[string][]*table
+	functions  map[string]function
+	aggregates map[string]aggregate
+	nextID     uint64
+	active     map[uint64]*Tx
+	// dead counts the versions that died since the last Vacuum
+	dead      int
+	vacuuming bool
+	// vacuumMu keeps a Vacuum from running while another one does
+	vacuumMu sync.Mutex
 }
 
 func NewMemoryBackend() *MemoryBackend {
-	return &MemoryBackend{tables: map[string]*table{}}
+	return &MemoryBackend{
+		tables:     map[string][]*table{},
+		functions:  map[string]function{},
+		aggregates: map[string]aggregate{},
+		nextID:     1,
+		active:     map[uint64]*Tx{},
+	}
+}
+
+// autocommit runs a statement in a transaction of its own.
+func (mb *MemoryBackend) autocommit(statement func(tx *Tx) error) error {
+	tx := mb.Begin()
+	if err := statement(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (mb *MemoryBackend) CreateTable(crt *CreateTableStatement) error {
-	t := table{}
-	mb.tables[crt.name.value] = &t
-	if crt.cols == nil {
-		return nil
+	return mb.autocommit(func(tx *Tx) error {
+		return tx.CreateTable(crt)
+	})
+}
+
+// newTable returns the table a CREATE TABLE statement describes.
+func newTable(crt *CreateTableStatement) (*table, error) {
+	t := &table{}
+	if crt.Cols == nil {
+		return t, nil
 	}
 
-	for _, col := range *crt.cols {
-		t.columns = append(t.columns, col.name.value)
+	for _, col := range *crt.Cols {
+		t.columns = append(t.columns, col.Name.Value)
 
-		var dt ColumnType
-		switch col.datatype.value {
-		case "int":
-			dt = IntType
-		case "text":
-			dt = TextType
-		default:
-			return ErrInvalidDatatype
+		dt, err := datatypeToColumnType(col.Datatype)
+		if err != nil {
+			return nil, err
 		}
 
 		t.columnTypes = append(t.columnTypes, dt)
 	}
 
-	return nil
+	return t, nil
+}
+
+func datatypeToColumnType(datatype Token) (ColumnType, error) {
+	switch datatype.Value {
+	case "int":
+		return IntType, nil
+	case "text":
+		return TextType, nil
+	case "json":
+		return JSONType, nil
+	case "boolean":
+		return BoolType, nil
+	}
+
+	return 0, ErrInvalidDatatype
 }
 
 func (mb *MemoryBackend) Insert(inst *InsertStatement) error {
-	table, ok := mb.tables[inst.table.value]
-	if !ok {
-		return ErrTableDoesNotExist
+	return mb.autocommit(func(tx *Tx) error {
+		return tx.Insert(inst)
+	})
+}
+
+// evaluateRow evaluates the values of an INSERT statement into a row of
+// the table.
+func (mb *MemoryBackend) evaluateRow(t *table, inst *InsertStatement) ([]MemoryCell, error) {
+	// Values can't refer to columns, so they're evaluated against an
+	// empty table
+	empty := &table{}
+	row := []MemoryCell{}
+
+	if len(*inst.Values) != len(t.columns) {
+		return nil, ErrMissingValues
+	}
+
+	for i, val := range *inst.Values {
+		cell, _, typ, err := mb.evaluateCell(empty, nil, val)
+		if err == nil {
+			cell, err = assignCell(cell, typ, t.columnTypes[i])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", t.columns[i], err)
+		}
+
+		row = append(row, cell)
 	}
 
-	if inst.values == nil {
-		return nil
+	return row, nil
+}
+
+// assignCell implicitly converts a value of one type into a value that
+// can be stored in a column of another. Integers are stored in text
+// columns as their decimal representation, text is stored in integer
+// columns if it holds a valid integer and in JSON columns if it holds a
+// valid JSON document.
+func assignCell(value MemoryCell, from, to ColumnType) (MemoryCell, error) {
+	implicit := from == to || from == NullType ||
+		(from == IntType && to == TextType) ||
+		(from == TextType && (to == IntType || to == JSONType))
+	if !implicit {
+		return nil, fmt.Errorf("%w: cannot assign %s to %s", ErrTypeMismatch, from, to)
 	}
 
-	row := []MemoryCell{}
+	cell, err := castCell(value, from, to)
+	if errors.Is(err, ErrInvalidCast) {
+		return nil, fmt.Errorf("%w: invalid input for type %s: %q", ErrTypeMismatch, to, value.AsText())
+	}
+
+	return cell, err
+}
+
+// castable reports whether values of one type can be explicitly
+// converted to another.
+func castable(from, to ColumnType) bool {
+	switch {
+	case from == to, from == NullType, to == TextType:
+		return true
+	case to == JSONType:
+		return from == TextType || from == IntType
+	case to == IntType:
+		return from == TextType || from == JSONType
+	case to == BoolType:
+		return from == TextType
+	}
+
+	return false
+}
+
+// castCell explicitly converts a value between two types. Text and JSON
+// can be converted to integers only when they hold an integer, text can be
+// converted to JSON only when it holds a valid document or to a boolean
+// when it holds one of true, false, t, f, yes, no, 1 or 0, and every type
+// can be converted to text.
+func castCell(value MemoryCell, from, to ColumnType) (MemoryCell, error) {
+	if !castable(from, to) {
+		return nil, fmt.Errorf("%w: cannot cast %s to %s", ErrInvalidCast, from, to)
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+
+	switch {
+	case from == BoolType && to == TextType:
+		b, err := value.AsBool()
+		if err != nil {
+			return nil, err
+		}
+
+		return MemoryCell(strconv.FormatBool(b)), nil
+	case from == TextType && to == BoolType:
+		switch strings.ToLower(strings.TrimSpace(value.AsText())) {
+		case "true", "t", "yes", "1":
+			return newBoolCell(true), nil
+		case "false", "f", "no", "0":
+			return newBoolCell(false), nil
+		}
+
+		return nil, fmt.Errorf("%w: invalid input for type %s: %q", ErrInvalidCast, to, value.AsText())
+	case to == JSONType && from != IntType:
+		if !json.Valid(value) {
+			return nil, ErrInvalidJSON
+		}
+
+		return value, nil
+	case from == IntType && to != IntType:
+		i, err := value.AsInt()
+		if err != nil {
+			return nil, err
+		}
+
+		return MemoryCell(strconv.Itoa(int(i))), nil
+	case from != IntType && to == IntType:
+		cell, err := intToCell(strings.TrimSpace(value.AsText()))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid input for type %s: %q", ErrInvalidCast, to, value.AsText())
+		}
+
+		return cell, nil
+	}
+
+	return value, nil
+}
+
+// intToCell encodes the decimal string s as an integer cell.
+func intToCell(s string) (MemoryCell, error) {
+	i, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return nil, ErrInvalidInteger
+	}
+
+	return newIntCell(int32(i)), nil
+}
+
+func newIntCell(i int32) MemoryCell {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.BigEndian, i); err != nil {
+		panic(err)
+	}
+
+	return MemoryCell(buf.Bytes())
+}
+
+func newBoolCell(b bool) MemoryCell {
+	if b {
+		return MemoryCell{1}
+	}
+
+	return MemoryCell{0}
+}
+
+// compareCells orders two non-NULL cells of the same type, returning a
+// negative number, zero or a positive number like bytes.Compare.
+func compareCells(a, b MemoryCell, typ ColumnType) (int, error) {
+	if typ != IntType {
+		return bytes.Compare(a, b), nil
+	}
+
+	ai, err := a.AsInt()
+	if err != nil {
+		return 0, err
+	}
+
+	bi, err := b.AsInt()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case ai < bi:
+		return -1, nil
+	case ai > bi:
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+func (mb *MemoryBackend) tokenToCell(t *Token) (MemoryCell, error) {
+	if t.Kind == NumericKind {
+		return intToCell(t.Value)
+	}
+	if t.Kind == StringKind {
+		return MemoryCell(t.Value), nil
+	}
+	if t.Kind == BoolKind {
+		return newBoolCell(t.Value == string(trueKeyword)), nil
+	}
+	return nil, nil
+}
+
+// expressionColumn determines the name and type of the column an
+// expression produces against rows of a table without evaluating it,
+// validating operand and function argument types along the way.
+func (mb *MemoryBackend) expressionColumn(t *table, exp *Expression) (string, ColumnType, error) {
+	switch exp.Kind {
+	case LiteralKind:
+		lit := exp.Literal
+		switch lit.Kind {
+		case IdentifierKind:
+			for i, tableCol := range t.columns {
+				if tableCol == lit.Value {
+					return lit.Value, t.columnTypes[i], nil
+				}
+			}
+
+			return "", 0, ErrColumnDoesNotExist
+		case NumericKind:
+			return lit.Value, IntType, nil
+		case StringKind:
+			return lit.Value, TextType, nil
+		case BoolKind:
+			return lit.Value, BoolType, nil
+		case NullKind, ParameterKind:
+			return "?column?", NullType, nil
+		}
+	case BinaryKind:
+		_, lt, err := mb.expressionColumn(t, &exp.Binary.A)
+		if err != nil {
+			return "", 0, err
+		}
+
+		_, rt, err := mb.expressionColumn(t, &exp.Binary.B)
+		if err != nil {
+			return "", 0, err
+		}
+
+		typ, err := binaryType(exp.Binary.Op, lt, rt)
+		return "?column?", typ, err
+	case CallKind:
+		var check func([]ColumnType) (ColumnType, error)
+		if fn, ok := mb.lookupFunction(exp.Call.Name.Value); ok {
+			check = fn.check
+		} else if agg, ok := mb.lookupAggregate(exp.Call.Name.Value); ok {
+			check = agg.check
+		} else if _, ok := windowFunctions[exp.Call.Name.Value]; ok {
+			return "", 0, ErrWindowRequiresOver
+		} else {
+			return "", 0, ErrFunctionNotFound
+		}
+
+		var types []ColumnType
+		for _, arg := range *exp.Call.Args {
+			_, typ, err := mb.expressionColumn(t, arg)
+			if err != nil {
+				return "", 0, err
+			}
+
+			types = append(types, typ)
+		}
+
+		typ, err := check(types)
+		return exp.Call.Name.Value, typ, err
+	case CastKind:
+		name, from, err := mb.expressionColumn(t, &exp.Cast.Exp)
+		if err != nil {
+			return "", 0, err
+		}
+
+		to, err := datatypeToColumnType(exp.Cast.Datatype)
+		if err != nil {
+			return "", 0, err
+		}
+
+		if !castable(from, to) {
+			return "", 0, fmt.Errorf("%w: cannot cast %s to %s", ErrInvalidCast, from, to)
+		}
+
+		return name, to, nil
+	case CaseKind:
+		typ, err := mb.caseType(t, exp.Case)
+		return "case", typ, err
+	case InKind, BetweenKind, LikeKind:
+		return "?column?", BoolType, mb.checkPredicate(t, exp)
+	}
+
+	return "", 0, ErrInvalidCell
+}
+
+// caseType validates the WHEN clauses of a CASE expression and returns the
+// type its THEN and ELSE branches unify to.
+func (mb *MemoryBackend) caseType(t *table, caseExp *CaseExpression) (ColumnType, error) {
+	whenTypes := []ColumnType{}
+	if caseExp.Operand != nil {
+		_, typ, err := mb.expressionColumn(t, caseExp.Operand)
+		if err != nil {
+			return 0, err
+		}
+
+		whenTypes = append(whenTypes, typ)
+	}
+
+	var branchTypes []ColumnType
+	for _, w := range *caseExp.Whens {
+		_, typ, err := mb.expressionColumn(t, w.When)
+		if err != nil {
+			return 0, err
+		}
+
+		if caseExp.Operand == nil && typ != BoolType && typ != NullType {
+			return 0, fmt.Errorf("%w: WHEN condition must be %s, got %s", ErrTypeMismatch, BoolType, typ)
+		}
+		whenTypes = append(whenTypes, typ)
+
+		_, typ, err = mb.expressionColumn(t, w.Then)
+		if err != nil {
+			return 0, err
+		}
+		branchTypes = append(branchTypes, typ)
+	}
+
+	if caseExp.Operand != nil {
+		if _, err := unifyTypes(whenTypes); err != nil {
+			return 0, err
+		}
+	}
+
+	if caseExp.Else != nil {
+		_, typ, err := mb.expressionColumn(t, caseExp.Else)
+		if err != nil {
+			return 0, err
+		}
+		branchTypes = append(branchTypes, typ)
+	}
+
+	return unifyTypes(branchTypes)
+}
+
+// isConstant reports whether an expression produces the same value for
+// every row, i.e. it refers to no columns and calls no volatile functions.
+func (mb *MemoryBackend) isConstant(exp *Expression) bool {
+	switch exp.Kind {
+	case LiteralKind:
+		return exp.Literal.Kind != IdentifierKind
+	case CallKind:
+		fn, ok := mb.lookupFunction(exp.Call.Name.Value)
+		if !ok || fn.volatile {
+			return false
+		}
+	}
+
+	for _, sub := range exp.subexpressions() {
+		if !mb.isConstant(sub) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// binaryType validates the operand types of a binary operator and returns
+// the type of the value it produces.
+func binaryType(op Token, lt, rt ColumnType) (ColumnType, error) {
+	if op.Kind == KeywordKind {
+		validLeft := lt == BoolType || lt == NullType
+		validRight := rt == BoolType || rt == NullType
+		if !validLeft || !validRight {
+			return 0, ErrInvalidOperands
+		}
+
+		return BoolType, nil
+	}
+
+	switch symbol(op.Value) {
+	case eqSymbol, neqSymbol, neqSymbol2, ltSymbol, lteSymbol, gtSymbol, gteSymbol:
+		if _, err := unifyTypes([]ColumnType{lt, rt}); err != nil {
+			return 0, fmt.Errorf("%w: cannot compare %s and %s", ErrInvalidOperands, lt, rt)
+		}
+
+		return BoolType, nil
+	case arrowSymbol, doubleArrowSymbol:
+		validLeft := lt == JSONType || lt == NullType
+		validRight := rt == TextType || rt == IntType || rt == NullType
+		if !validLeft || !validRight {
+			return 0, ErrInvalidOperands
+		}
 
-	if len(*inst.values) != len(table.columns) {
-		return ErrMissingValues
+		if op.Value == string(doubleArrowSymbol) {
+			return TextType, nil
+		}
+
+		return JSONType, nil
+	case tildeSymbol:
+		validLeft := lt == TextType || lt == NullType
+		validRight := rt == TextType || rt == NullType
+		if !validLeft || !validRight {
+			return 0, ErrInvalidOperands
+		}
+
+		return BoolType, nil
+	}
+
+	return 0, ErrInvalidOperands
+}
+
+// evaluateCell computes the value of an expression against a single row
+// of a table, returning the resulting cell along with the name and type of
+// the column it produces.
+func (mb *MemoryBackend) evaluateCell(t *table, row []MemoryCell, exp *Expression) (MemoryCell, string, ColumnType, error) {
+	switch exp.Kind {
+	case LiteralKind:
+		return mb.evaluateLiteralCell(t, row, exp)
+	case BinaryKind:
+		return mb.evaluateBinaryCell(t, row, exp)
+	case CallKind:
+		return mb.evaluateCallCell(t, row, exp)
+	case CastKind:
+		return mb.evaluateCastCell(t, row, exp)
+	case CaseKind:
+		return mb.evaluateCaseCell(t, row, exp)
+	case InKind, BetweenKind, LikeKind:
+		return mb.evaluatePredicateCell(t, row, exp)
 	}
 
-	for _, val := range *inst.values {
-		if val.kind != literalKind {
-			fmt.Println("Skipping non-literal.")
+	return nil, "", 0, ErrInvalidCell
+}
+
+// evaluateLogic implements AND and OR with SQL's three-valued logic, where
+// NULL stands for an unknown truth value.
+func evaluateLogic(op keyword, l, r MemoryCell) (MemoryCell, error) {
+	// The value that decides the result regardless of the other operand
+	decisive := op == orKeyword
+
+	var unknown bool
+	for _, operand := range []MemoryCell{l, r} {
+		if operand == nil {
+			unknown = true
 			continue
 		}
 
-		row = append(row, mb.tokenToCell(val.literal))
+		b, err := operand.AsBool()
+		if err != nil {
+			return nil, err
+		}
+
+		if b == decisive {
+			return newBoolCell(decisive), nil
+		}
+	}
+
+	if unknown {
+		return nil, nil
 	}
 
-	table.rows = append(table.rows, row)
-	return nil
+	return newBoolCell(!decisive), nil
 }
 
-func (mb *MemoryBackend) tokenToCell(t *token) MemoryCell {
-	if t.kind == numericKind {
-		buf := new(bytes.Buffer)
-		i, err := strconv.Atoi(t.value)
+func (mb *MemoryBackend) evaluateLiteralCell(t *table, row []MemoryCell, exp *Expression) (MemoryCell, string, ColumnType, error) {
+	lit := exp.Literal
+	switch lit.Kind {
+	case IdentifierKind:
+		for i, tableCol := range t.columns {
+			if tableCol == lit.Value {
+				return row[i], lit.Value, t.columnTypes[i], nil
+			}
+		}
+
+		return nil, "", 0, ErrColumnDoesNotExist
+	case NumericKind:
+		cell, err := mb.tokenToCell(lit)
+		return cell, lit.Value, IntType, err
+	case StringKind:
+		cell, err := mb.tokenToCell(lit)
+		return cell, lit.Value, TextType, err
+	case BoolKind:
+		cell, err := mb.tokenToCell(lit)
+		return cell, lit.Value, BoolType, err
+	case NullKind:
+		return nil, "?column?", NullType, nil
+	case ParameterKind:
+		return nil, "", 0, fmt.Errorf("%w: %s", ErrUnboundParameter, lit.Value)
+	}
+
+	return nil, "", 0, ErrInvalidCell
+}
+
+func (mb *MemoryBackend) evaluateBinaryCell(t *table, row []MemoryCell, exp *Expression) (MemoryCell, string, ColumnType, error) {
+	bexp := exp.Binary
+
+	l, _, lt, err := mb.evaluateCell(t, row, &bexp.A)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	r, _, rt, err := mb.evaluateCell(t, row, &bexp.B)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	typ, err := binaryType(bexp.Op, lt, rt)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	if bexp.Op.Kind == KeywordKind {
+		value, err := evaluateLogic(keyword(bexp.Op.Value), l, r)
+		return value, "?column?", typ, err
+	}
+
+	switch symbol(bexp.Op.Value) {
+	case eqSymbol, neqSymbol, neqSymbol2, ltSymbol, lteSymbol, gtSymbol, gteSymbol:
+		if l == nil || r == nil {
+			return nil, "?column?", typ, nil
+		}
+
+		operandType, _ := unifyTypes([]ColumnType{lt, rt})
+		cmp, err := compareCells(l, r, operandType)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		var result bool
+		switch symbol(bexp.Op.Value) {
+		case eqSymbol:
+			result = cmp == 0
+		case neqSymbol, neqSymbol2:
+			result = cmp != 0
+		case ltSymbol:
+			result = cmp < 0
+		case lteSymbol:
+			result = cmp <= 0
+		case gtSymbol:
+			result = cmp > 0
+		case gteSymbol:
+			result = cmp >= 0
+		}
+
+		return newBoolCell(result), "?column?", typ, nil
+	case arrowSymbol, doubleArrowSymbol:
+		if r.IsNull() {
+			return nil, "?column?", typ, nil
+		}
+
+		var member MemoryCell
+		switch rt {
+		case TextType:
+			member, err = jsonMember(l, r.AsText())
+		case IntType:
+			var i int32
+			i, err = r.AsInt()
+			if err == nil {
+				member, err = jsonElement(l, int(i))
+			}
+		}
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		if typ == TextType {
+			member, err = jsonToText(member)
+		}
+
+		return member, "?column?", typ, err
+	case tildeSymbol:
+		if l == nil || r == nil {
+			return nil, "?column?", typ, nil
+		}
+
+		constant := func() bool { return mb.isConstant(&bexp.B) }
+		match, err := t.matcher(exp, constant, func() (matcher, error) {
+			re, err := regexp.Compile(r.AsText())
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidPattern, err)
+			}
+
+			return re.Match, nil
+		})
 		if err != nil {
-			panic(err)
+			return nil, "", 0, err
+		}
+
+		return newBoolCell(match(l)), "?column?", typ, nil
+	}
+
+	return nil, "", 0, ErrInvalidOperands
+}
+
+func (mb *MemoryBackend) evaluateCallCell(t *table, row []MemoryCell, exp *Expression) (MemoryCell, string, ColumnType, error) {
+	call := exp.Call
+
+	fn, ok := mb.calledFunction(t, exp)
+	if !ok {
+		// Aggregates are only evaluated by groupRows
+		if _, ok := mb.lookupAggregate(call.Name.Value); ok {
+			return nil, "", 0, ErrMisplacedAggregate
 		}
 
-		if err := binary.Write(buf, binary.BigEndian, int32(i)); err != nil {
-			panic(err)
+		if _, ok := windowFunctions[call.Name.Value]; ok {
+			return nil, "", 0, ErrWindowRequiresOver
+		}
+
+		return nil, "", 0, ErrFunctionNotFound
+	}
+
+	var args []MemoryCell
+	var types []ColumnType
+	for _, arg := range *call.Args {
+		value, _, typ, err := mb.evaluateCell(t, row, arg)
+		if err != nil {
+			return nil, "", 0, err
 		}
-		return MemoryCell(buf.Bytes())
+
+		args = append(args, value)
+		types = append(types, typ)
 	}
-	if t.kind == stringKind {
-		return MemoryCell(t.value)
+
+	typ, err := fn.check(types)
+	if err != nil {
+		return nil, "", 0, err
 	}
-	return nil
+
+	value, err := fn.eval(args, types, typ)
+	return value, call.Name.Value, typ, err
+}
+
+func (mb *MemoryBackend) evaluateCastCell(t *table, row []MemoryCell, exp *Expression) (MemoryCell, string, ColumnType, error) {
+	value, name, from, err := mb.evaluateCell(t, row, &exp.Cast.Exp)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	to, err := datatypeToColumnType(exp.Cast.Datatype)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	value, err = castCell(value, from, to)
+	return value, name, to, err
+}
+
+func (mb *MemoryBackend) evaluateCaseCell(t *table, row []MemoryCell, exp *Expression) (MemoryCell, string, ColumnType, error) {
+	caseExp := exp.Case
+
+	typ, err := t.checkedType(exp, func() (ColumnType, error) {
+		return mb.caseType(t, caseExp)
+	})
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var operand MemoryCell
+	var operandType ColumnType
+	if caseExp.Operand != nil {
+		operand, _, operandType, err = mb.evaluateCell(t, row, caseExp.Operand)
+		if err != nil {
+			return nil, "", 0, err
+		}
+	}
+
+	for _, w := range *caseExp.Whens {
+		when, _, whenType, err := mb.evaluateCell(t, row, w.When)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		var matched bool
+		if caseExp.Operand == nil {
+			if when != nil {
+				matched, err = when.AsBool()
+			}
+		} else if operand != nil && when != nil {
+			var cmp int
+			cmpType, _ := unifyTypes([]ColumnType{operandType, whenType})
+			cmp, err = compareCells(operand, when, cmpType)
+			matched = cmp == 0
+		}
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		if matched {
+			value, _, _, err := mb.evaluateCell(t, row, w.Then)
+			return value, "case", typ, err
+		}
+	}
+
+	if caseExp.Else == nil {
+		return nil, "case", typ, nil
+	}
+
+	value, _, _, err := mb.evaluateCell(t, row, caseExp.Else)
+	return value, "case", typ, err
 }
 
 func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
-	table := &table{}
+	return mb.query(nil, slct)
+}
+
+// filterRows removes the rows of t the WHERE condition isn't true for.
+func (mb *MemoryBackend) filterRows(t *table, where *Expression) error {
+	_, typ, err := mb.expressionColumn(t, where)
+	if err != nil {
+		return err
+	}
+
+	if typ != BoolType && typ != NullType {
+		return fmt.Errorf("%w: WHERE condition must be %s, got %s", ErrTypeMismatch, BoolType, typ)
+	}
+
+	calls, err := mb.collectAggregates(where, nil, false)
+	if err != nil {
+		return err
+	}
 
-	if slct.from != nil && slct.from.table != nil {
+	if len(calls) > 0 {
+		return ErrMisplacedAggregate
+	}
+
+	rows := [][]MemoryCell{}
+	for _, row := range t.rows {
+		value, _, _, err := mb.evaluateCell(t, row, where)
+		if err != nil {
+			return err
+		}
+
+		if value == nil {
+			continue
+		}
+
+		keep, err := value.AsBool()
+		if err != nil {
+			return err
+		}
+
+		if keep {
+			rows = append(rows, row)
+		}
+	}
+
+	t.rows = rows
+	return nil
+}
+
+// query runs a select against the tables seen from the snapshot, or as of
+// now when s is nil.
+func (mb *MemoryBackend) query(s *snapshot, slct *SelectStatement) (*Results, error) {
+	// A single empty row lets selects without FROM evaluate their items once
+	t := &table{rows: [][]MemoryCell{{}}}
+
+	if slct.From != nil && slct.From.Table != nil {
 		var ok bool
-		table, ok = mb.tables[slct.from.table.value]
+		t, ok = mb.lookupTable(s, slct.From.Table.Value, slct.Where)
 		if !ok {
 			return nil, ErrTableDoesNotExist
 		}
 	}
 
-	if slct.item == nil || len(*slct.item) == 0 {
+	if slct.Item == nil || len(*slct.Item) == 0 {
 		return &Results{}, nil
 	}
 
-	results := [][]Cell{}
+	// ORDER BY and DISTINCT ON expressions are evaluated as hidden items
+	// after the visible ones so that grouping and windows apply to them
+	visible := *slct.Item
+
+	var orderBy []*SelectItem
+	var desc []bool
+	if slct.OrderBy != nil {
+		var exps []*Expression
+		for _, ob := range *slct.OrderBy {
+			exps = append(exps, ob.Exp)
+			desc = append(desc, ob.Desc)
+		}
+
+		var err error
+		orderBy, err = sortItems(visible, exps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var distinctOn []*SelectItem
+	if slct.DistinctOn != nil {
+		var err error
+		distinctOn, err = sortItems(visible, *slct.DistinctOn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkDistinct(slct, orderBy, distinctOn); err != nil {
+		return nil, err
+	}
+
+	items := append(append(append([]*SelectItem{}, visible...), orderBy...), distinctOn...)
+	hidden := len(orderBy) + len(distinctOn)
+
 	columns := []struct {
 		Type ColumnType
 		Name string
 	}{}
+	var orderByTypes []ColumnType
 
-	if slct.from == nil {
-		result := []MemoryCell{}
-		for _, col := range *slct.item {
-			nonImmediateLiteral := !col.asterisk && col.exp.kind == literalKind && !(col.exp.literal.kind == numericKind)
-			if nonImmediateLiteral || col.asterisk || col.exp.kind != literalKind {
-				return nil, ErrInvalidSelectItem
+	// Validate every item and describe its column before touching any rows
+	for i, col := range items {
+		if col.Asterisk {
+			continue
+		}
+
+		var columnName string
+		var columnType ColumnType
+		var err error
+		if col.Over != nil {
+			columnName, columnType, err = mb.windowColumn(t, col)
+		} else {
+			columnName, columnType, err = mb.expressionColumn(t, col.Exp)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if i >= len(visible) {
+			if i < len(visible)+len(orderBy) {
+				orderByTypes = append(orderByTypes, columnType)
 			}
+			continue
+		}
 
-			result = append(result, mb.tokenToCell(col.exp.literal))
+		if col.As != nil {
+			columnName = col.As.Value
 		}
+
+		columns = append(columns, struct {
+			Type ColumnType
+			Name string
+		}{
+			Type: columnType,
+			Name: columnName,
+		})
 	}
 
-	for _, row := range table.rows {
-		result := []Cell{}
+	if slct.Where != nil {
+		if err := mb.filterRows(t, slct.Where); err != nil {
+			return nil, err
+		}
+	}
 
-		for _, col := range *slct.item {
-			if col.asterisk {
-				// TODO: handle asterisk
-				fmt.Println("Skipping asterisk.")
-				continue
-			}
+	if mb.isAggregating(items, slct.GroupBy) {
+		if hasWindows(items) {
+			return nil, fmt.Errorf("%w: window functions can't be combined with aggregation", ErrInvalidWindowFunction)
+		}
 
-			exp := col.exp
-			if exp.kind != literalKind {
-				// Unsupported, doesn't currently exist, ignore.
-				fmt.Println("Skipping non-literal expression.")
-				continue
+		var err error
+		t, items, err = mb.groupRows(t, items, slct.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if hasWindows(items) {
+		var err error
+		t, items, err = mb.windowRows(t, items)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Constant items only need to be evaluated once
+	constants := map[*SelectItem]MemoryCell{}
+	for _, col := range items {
+		if !col.Asterisk && mb.isConstant(col.Exp) && len(t.rows) > 0 {
+			value, _, _, err := mb.evaluateCell(t, nil, col.Exp)
+			if err != nil {
+				return nil, err
 			}
 
-			lit := exp.literal
-			if lit.kind == identifierKind {
-				found := false
-				for i, tableCol := range table.columns {
-					if tableCol == lit.value {
-						columns = append(columns, struct {
-							Type ColumnType
-							Name string
-						}{
-							Type: table.columnTypes[i],
-							Name: lit.value,
-						})
-
-						result = append(result, row[i])
-						found = true
-						break
-					}
-				}
+			constants[col] = value
+		}
+	}
 
-				if !found {
-					return nil, ErrColumnDoesNotExist
-				}
+	rows := [][]MemoryCell{}
+	for _, row := range t.rows {
+		result := []MemoryCell{}
 
+		for _, col := range items {
+			if col.Asterisk {
+				// TODO: handle asterisk
+				fmt.Println("Skipping asterisk.")
 				continue
 			}
 
-			if lit.kind == numericKind || lit.kind == stringKind {
-				columnType := IntType
-				if lit.kind == stringKind {
-					columnType = TextType
+			value, ok := constants[col]
+			if !ok {
+				var err error
+				value, _, _, err = mb.evaluateCell(t, row, col.Exp)
+				if err != nil {
+					return nil, err
 				}
+			}
 
-				columns = append(columns, struct {
-					Type ColumnType
-					Name string
-				}{
-					Type: columnType,
-					Name: col.exp.literal.value,
-				})
-				result = append(result, mb.tokenToCell(lit))
-				continue
+			result = append(result, value)
+		}
+
+		rows = append(rows, result)
+	}
+
+	if len(rows) > 0 {
+		width := len(rows[0])
+		orderByStart := width - hidden
+
+		if orderBy != nil {
+			if err := sortRows(rows, orderByStart, orderByTypes, desc); err != nil {
+				return nil, err
 			}
+		}
 
-			return nil, ErrColumnDoesNotExist
+		switch {
+		case distinctOn != nil:
+			rows = distinctRows(rows, orderByStart+len(orderBy), width)
+		case slct.Distinct:
+			rows = distinctRows(rows, 0, orderByStart)
+		}
+	}
+
+	results := [][]Cell{}
+	for _, row := range rows {
+		result := []Cell{}
+		for _, value := range row[:len(row)-hidden] {
+			result = append(result, value)
 		}
 
 		results = append(results, result)
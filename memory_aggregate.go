@@ -0,0 +1,661 @@
+package gosql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Aggregate is implemented by Go types that compute an aggregate function
+// over groups of rows. States are opaque to the backend, which only
+// passes them back into the same Aggregate.
+type Aggregate interface {
+	// Init returns the state of an empty group
+	Init() interface{}
+	// Step folds the arguments of one row into a state. Arguments use the
+	// same Go representation as ScalarFunction.Call, so booleans arrive as a
+	// bool.
+	Step(state interface{}, args []interface{}) (interface{}, error)
+	// Merge combines two partial states of the same group
+	Merge(a, b interface{}) (interface{}, error)
+	// Final produces the value of a group from its state. Window
+	// functions call it repeatedly as rows are stepped into a frame, so it
+	// must not modify the state.
+	Final(state interface{}) (interface{}, error)
+}
+
+// AggregateFunction describes an aggregate implemented in Go that can be
+// called from SQL once registered with RegisterAggregate.
+type AggregateFunction struct {
+	Args      []ColumnType
+	Returns   ColumnType
+	Aggregate Aggregate
+}
+
+type aggregate struct {
+	check func(args []ColumnType) (ColumnType, error)
+	impl  Aggregate
+}
+
+var builtinAggregates = map[string]aggregate{
+	"count": {
+		check: func(args []ColumnType) (ColumnType, error) {
+			if len(args) > 1 {
+				return 0, fmt.Errorf("%w: expected at most 1 argument, got %d", ErrInvalidArguments, len(args))
+			}
+
+			return IntType, nil
+		},
+		impl: countAggregate{},
+	},
+	"sum": {
+		check: signature(IntType, 0, IntType),
+		impl:  sumAggregate{},
+	},
+	"min": {
+		check: unifying(1, 1),
+		impl:  extremeAggregate{sign: -1},
+	},
+	"max": {
+		check: unifying(1, 1),
+		impl:  extremeAggregate{sign: 1},
+	},
+}
+
+// aggregateBatchSize is the number of rows folded into fresh partial
+// states before they are merged into the states of their groups.
+const aggregateBatchSize = 1024
+
+// RegisterAggregate makes a Go aggregate callable from SQL under the given
+// case-insensitive name, where it can be used with GROUP BY like the
+// built-in COUNT, SUM, MIN and MAX.
+func (mb *MemoryBackend) RegisterAggregate(name string, af AggregateFunction) error {
+	name = strings.ToLower(name)
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if mb.functionExists(name) {
+		return ErrFunctionExists
+	}
+
+	if af.Aggregate == nil {
+		return ErrInvalidArguments
+	}
+
+	mb.aggregates[name] = aggregate{
+		check: signature(af.Returns, 0, af.Args...),
+		impl:  af.Aggregate,
+	}
+
+	return nil
+}
+
+func (mb *MemoryBackend) lookupAggregate(name string) (aggregate, bool) {
+	if agg, ok := builtinAggregates[name]; ok {
+		return agg, true
+	}
+
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+
+	agg, ok := mb.aggregates[name]
+	return agg, ok
+}
+
+// isAggregating reports whether a select collapses its rows into groups,
+// either with a GROUP BY clause or by calling an aggregate.
+func (mb *MemoryBackend) isAggregating(items []*SelectItem, groupBy *[]*Expression) bool {
+	if groupBy != nil {
+		return true
+	}
+
+	for _, item := range items {
+		if item.Asterisk || item.Over != nil {
+			continue
+		}
+
+		calls, err := mb.collectAggregates(item.Exp, nil, false)
+		if err != nil || len(calls) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectAggregates appends the aggregate calls within an expression to
+// calls, rejecting aggregates nested within other aggregates.
+func (mb *MemoryBackend) collectAggregates(exp *Expression, calls []*Expression, nested bool) ([]*Expression, error) {
+	var err error
+	if exp.Kind == CallKind {
+		_, isAggregate := mb.lookupAggregate(exp.Call.Name.Value)
+		if isAggregate {
+			if nested {
+				return nil, ErrMisplacedAggregate
+			}
+
+			calls = append(calls, exp)
+		}
+		nested = nested || isAggregate
+	}
+
+	for _, sub := range exp.subexpressions() {
+		calls, err = mb.collectAggregates(sub, calls, nested)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return calls, nil
+}
+
+type group struct {
+	values []MemoryCell
+	states []interface{}
+}
+
+// groupRows folds the rows of t into one row per group. The resulting
+// table holds the GROUP BY values of each group followed by the results
+// of each aggregate call, and the select items are rewritten to refer to
+// those columns.
+func (mb *MemoryBackend) groupRows(t *table, items []*SelectItem, groupByList *[]*Expression) (*table, []*SelectItem, error) {
+	groupBy := []*Expression{}
+	if groupByList != nil {
+		groupBy = *groupByList
+	}
+
+	grouped := &table{}
+	for i, exp := range groupBy {
+		calls, err := mb.collectAggregates(exp, nil, false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(calls) > 0 {
+			return nil, nil, ErrMisplacedAggregate
+		}
+
+		_, typ, err := mb.expressionColumn(t, exp)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		grouped.columns = append(grouped.columns, fmt.Sprintf("?group%d", i))
+		grouped.columnTypes = append(grouped.columnTypes, typ)
+	}
+
+	var calls []*Expression
+	for _, item := range items {
+		if item.Asterisk {
+			continue
+		}
+
+		var err error
+		calls, err = mb.collectAggregates(item.Exp, calls, false)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var aggs []aggregate
+	var argTypes [][]ColumnType
+	for i, call := range calls {
+		agg, _ := mb.lookupAggregate(call.Call.Name.Value)
+		aggs = append(aggs, agg)
+
+		var types []ColumnType
+		for _, arg := range *call.Call.Args {
+			_, typ, err := mb.expressionColumn(t, arg)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			types = append(types, typ)
+		}
+		argTypes = append(argTypes, types)
+
+		returns, err := agg.check(types)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		grouped.columns = append(grouped.columns, fmt.Sprintf("?aggregate%d", i))
+		grouped.columnTypes = append(grouped.columnTypes, returns)
+	}
+
+	groups := map[string]*group{}
+	var order []string
+	for start := 0; start < len(t.rows); start += aggregateBatchSize {
+		end := start + aggregateBatchSize
+		if end > len(t.rows) {
+			end = len(t.rows)
+		}
+
+		batch, batchOrder, err := mb.aggregateBatch(t, t.rows[start:end], groupBy, calls, aggs, argTypes)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, key := range batchOrder {
+			g, ok := groups[key]
+			if !ok {
+				groups[key] = batch[key]
+				order = append(order, key)
+				continue
+			}
+
+			for i, agg := range aggs {
+				g.states[i], err = agg.impl.Merge(g.states[i], batch[key].states[i])
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	// Without GROUP BY there is always exactly one group, even if empty
+	if len(groupBy) == 0 && len(order) == 0 {
+		g := &group{}
+		for _, agg := range aggs {
+			g.states = append(g.states, agg.impl.Init())
+		}
+
+		groups[""] = g
+		order = append(order, "")
+	}
+
+	for _, key := range order {
+		g := groups[key]
+
+		row := append([]MemoryCell{}, g.values...)
+		for i, agg := range aggs {
+			result, err := agg.impl.Final(g.states[i])
+			if err != nil {
+				return nil, nil, err
+			}
+
+			cell, err := valueToCell(result, grouped.columnTypes[len(groupBy)+i])
+			if err != nil {
+				return nil, nil, err
+			}
+
+			row = append(row, cell)
+		}
+
+		grouped.rows = append(grouped.rows, row)
+	}
+
+	var groupedItems []*SelectItem
+	for _, item := range items {
+		if item.Asterisk {
+			groupedItems = append(groupedItems, item)
+			continue
+		}
+
+		exp, err := groupedExpression(item.Exp, groupBy, calls)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		groupedItems = append(groupedItems, &SelectItem{Exp: exp, As: item.As})
+	}
+
+	return grouped, groupedItems, nil
+}
+
+// aggregateBatch groups a batch of rows and folds them into fresh states,
+// returning the groups along with the order they were first seen in.
+func (mb *MemoryBackend) aggregateBatch(t *table, rows [][]MemoryCell, groupBy, calls []*Expression, aggs []aggregate, argTypes [][]ColumnType) (map[string]*group, []string, error) {
+	groups := map[string]*group{}
+	var order []string
+	for _, row := range rows {
+		var values []MemoryCell
+		var key strings.Builder
+		for _, exp := range groupBy {
+			value, _, _, err := mb.evaluateCell(t, row, exp)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			values = append(values, value)
+			writeGroupKey(&key, value)
+		}
+
+		g, ok := groups[key.String()]
+		if !ok {
+			g = &group{values: values}
+			for _, agg := range aggs {
+				g.states = append(g.states, agg.impl.Init())
+			}
+
+			groups[key.String()] = g
+			order = append(order, key.String())
+		}
+
+		for i, call := range calls {
+			var args []interface{}
+			for j, arg := range *call.Call.Args {
+				cell, _, _, err := mb.evaluateCell(t, row, arg)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				value, err := cellToValue(cell, argTypes[i][j])
+				if err != nil {
+					return nil, nil, err
+				}
+
+				args = append(args, value)
+			}
+
+			var err error
+			g.states[i], err = aggs[i].impl.Step(g.states[i], args)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return groups, order, nil
+}
+
+// writeGroupKey appends an unambiguous encoding of a cell to a group key,
+// distinguishing NULL from every other value.
+func writeGroupKey(key *strings.Builder, value MemoryCell) {
+	if value == nil {
+		key.WriteByte(0)
+		return
+	}
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(value)))
+
+	key.WriteByte(1)
+	key.Write(length[:])
+	key.Write(value)
+}
+
+// groupedExpression rewrites an expression evaluated per group so that
+// GROUP BY expressions and aggregate calls refer to the columns of the
+// table produced by groupRows.
+func groupedExpression(exp *Expression, groupBy, calls []*Expression) (*Expression, error) {
+	for i, g := range groupBy {
+		if exp.equals(g) {
+			return columnReference(fmt.Sprintf("?group%d", i)), nil
+		}
+	}
+
+	for i, call := range calls {
+		if exp == call {
+			return columnReference(fmt.Sprintf("?aggregate%d", i)), nil
+		}
+	}
+
+	grouped := func(exp *Expression) (*Expression, error) {
+		if exp == nil {
+			return nil, nil
+		}
+
+		return groupedExpression(exp, groupBy, calls)
+	}
+
+	groupedList := func(exps *[]*Expression) (*[]*Expression, error) {
+		list := []*Expression{}
+		for _, exp := range *exps {
+			g, err := grouped(exp)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, g)
+		}
+
+		return &list, nil
+	}
+
+	switch exp.Kind {
+	case LiteralKind:
+		if exp.Literal.Kind == IdentifierKind {
+			return nil, fmt.Errorf("%w: %s", ErrColumnNotGrouped, exp.Literal.Value)
+		}
+
+		return exp, nil
+	case BinaryKind:
+		a, err := grouped(&exp.Binary.A)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := grouped(&exp.Binary.B)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expression{
+			Binary: &BinaryExpression{A: *a, B: *b, Op: exp.Binary.Op},
+			Kind:   BinaryKind,
+		}, nil
+	case CastKind:
+		inner, err := grouped(&exp.Cast.Exp)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expression{
+			Cast: &CastExpression{Exp: *inner, Datatype: exp.Cast.Datatype},
+			Kind: CastKind,
+		}, nil
+	case CaseKind:
+		operand, err := grouped(exp.Case.Operand)
+		if err != nil {
+			return nil, err
+		}
+
+		whens := []*WhenClause{}
+		for _, w := range *exp.Case.Whens {
+			when, err := grouped(w.When)
+			if err != nil {
+				return nil, err
+			}
+
+			then, err := grouped(w.Then)
+			if err != nil {
+				return nil, err
+			}
+
+			whens = append(whens, &WhenClause{When: when, Then: then})
+		}
+
+		elseExp, err := grouped(exp.Case.Else)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expression{
+			Case: &CaseExpression{Operand: operand, Whens: &whens, Else: elseExp},
+			Kind: CaseKind,
+		}, nil
+	case InKind:
+		inner, err := grouped(&exp.In.Exp)
+		if err != nil {
+			return nil, err
+		}
+
+		list, err := groupedList(exp.In.List)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expression{
+			In:   &InExpression{Exp: *inner, List: list, Not: exp.In.Not},
+			Kind: InKind,
+		}, nil
+	case BetweenKind:
+		bounds, err := groupedList(&[]*Expression{&exp.Between.Exp, &exp.Between.Low, &exp.Between.High})
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expression{
+			Between: &BetweenExpression{
+				Exp:  *(*bounds)[0],
+				Low:  *(*bounds)[1],
+				High: *(*bounds)[2],
+				Not:  exp.Between.Not,
+			},
+			Kind: BetweenKind,
+		}, nil
+	case LikeKind:
+		inner, err := grouped(&exp.Like.Exp)
+		if err != nil {
+			return nil, err
+		}
+
+		pattern, err := grouped(&exp.Like.Pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		escape, err := grouped(exp.Like.Escape)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expression{
+			Like: &LikeExpression{
+				Exp:     *inner,
+				Pattern: *pattern,
+				Escape:  escape,
+				Op:      exp.Like.Op,
+				Not:     exp.Like.Not,
+			},
+			Kind: LikeKind,
+		}, nil
+	case CallKind:
+		args, err := groupedList(exp.Call.Args)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expression{
+			Call: &CallExpression{Name: exp.Call.Name, Args: args, Asterisk: exp.Call.Asterisk},
+			Kind: CallKind,
+		}, nil
+	}
+
+	return nil, ErrInvalidCell
+}
+
+func columnReference(name string) *Expression {
+	return &Expression{
+		Literal: &Token{Value: name, Kind: IdentifierKind},
+		Kind:    LiteralKind,
+	}
+}
+
+type countAggregate struct{}
+
+func (countAggregate) Init() interface{} {
+	return int64(0)
+}
+
+func (countAggregate) Step(state interface{}, args []interface{}) (interface{}, error) {
+	// COUNT(*) counts every row, COUNT(x) only those where x is not NULL
+	if len(args) == 0 || args[0] != nil {
+		return state.(int64) + 1, nil
+	}
+
+	return state, nil
+}
+
+func (countAggregate) Merge(a, b interface{}) (interface{}, error) {
+	return a.(int64) + b.(int64), nil
+}
+
+func (countAggregate) Final(state interface{}) (interface{}, error) {
+	return state, nil
+}
+
+type sumAggregate struct{}
+
+func (sumAggregate) Init() interface{} {
+	return nil
+}
+
+func (s sumAggregate) Step(state interface{}, args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return state, nil
+	}
+
+	return s.Merge(state, int64(args[0].(int32)))
+}
+
+func (sumAggregate) Merge(a, b interface{}) (interface{}, error) {
+	if a == nil {
+		return b, nil
+	}
+
+	if b == nil {
+		return a, nil
+	}
+
+	return a.(int64) + b.(int64), nil
+}
+
+func (sumAggregate) Final(state interface{}) (interface{}, error) {
+	return state, nil
+}
+
+// extremeAggregate implements MAX (sign 1) and MIN (sign -1).
+type extremeAggregate struct {
+	sign int
+}
+
+func (extremeAggregate) Init() interface{} {
+	return nil
+}
+
+func (e extremeAggregate) Step(state interface{}, args []interface{}) (interface{}, error) {
+	return e.Merge(state, args[0])
+}
+
+func (e extremeAggregate) Merge(a, b interface{}) (interface{}, error) {
+	if a == nil {
+		return b, nil
+	}
+
+	if b == nil {
+		return a, nil
+	}
+
+	var cmp int
+	switch av := a.(type) {
+	case int32:
+		bv := b.(int32)
+		if av < bv {
+			cmp = -1
+		} else if av > bv {
+			cmp = 1
+		}
+	case string:
+		cmp = strings.Compare(av, b.(string))
+	case bool:
+		// false sorts before true
+		if av != b.(bool) {
+			cmp = 1
+			if !av {
+				cmp = -1
+			}
+		}
+	}
+
+	if cmp*e.sign >= 0 {
+		return a, nil
+	}
+
+	return b, nil
+}
+
+func (extremeAggregate) Final(state interface{}) (interface{}, error) {
+	return state, nil
+}
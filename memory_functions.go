@@ -0,0 +1,474 @@
+package gosql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type function struct {
+	// check validates the types of the arguments to a call and returns
+	// the type of the value the call produces
+	check    func(args []ColumnType) (ColumnType, error)
+	eval     func(args []MemoryCell, types []ColumnType, returns ColumnType) (MemoryCell, error)
+	volatile bool
+}
+
+var builtinFunctions = map[string]function{
+	"lower": {
+		check: signature(TextType, 0, TextType),
+		eval: strict(func(args []MemoryCell) (MemoryCell, error) {
+			return MemoryCell(strings.ToLower(args[0].AsText())), nil
+		}),
+	},
+	"upper": {
+		check: signature(TextType, 0, TextType),
+		eval: strict(func(args []MemoryCell) (MemoryCell, error) {
+			return MemoryCell(strings.ToUpper(args[0].AsText())), nil
+		}),
+	},
+	"length": {
+		check: signature(IntType, 0, TextType),
+		eval: strict(func(args []MemoryCell) (MemoryCell, error) {
+			return newIntCell(int32(utf8.RuneCount(args[0]))), nil
+		}),
+	},
+	"substr": {
+		check: signature(TextType, 1, TextType, IntType, IntType),
+		eval:  strict(evalSubstr),
+	},
+	"trim": {
+		check: signature(TextType, 1, TextType, TextType),
+		eval: strict(func(args []MemoryCell) (MemoryCell, error) {
+			cutset := " "
+			if len(args) == 2 {
+				cutset = args[1].AsText()
+			}
+
+			return MemoryCell(strings.Trim(args[0].AsText(), cutset)), nil
+		}),
+	},
+	"replace": {
+		check: signature(TextType, 0, TextType, TextType, TextType),
+		eval: strict(func(args []MemoryCell) (MemoryCell, error) {
+			s, from, to := args[0].AsText(), args[1].AsText(), args[2].AsText()
+			if from == "" {
+				return args[0], nil
+			}
+
+			return MemoryCell(strings.Replace(s, from, to, -1)), nil
+		}),
+	},
+	"abs": {
+		check: signature(IntType, 0, IntType),
+		eval: strict(func(args []MemoryCell) (MemoryCell, error) {
+			i, err := args[0].AsInt()
+			if err != nil {
+				return nil, err
+			}
+
+			if i >= 0 {
+				return args[0], nil
+			}
+
+			if -i < 0 {
+				return nil, ErrInvalidInteger
+			}
+
+			return newIntCell(-i), nil
+		}),
+	},
+	"round": {
+		check: signature(IntType, 1, IntType, IntType),
+		eval:  strict(evalRound),
+	},
+	"mod": {
+		check: signature(IntType, 0, IntType, IntType),
+		eval: strict(func(args []MemoryCell) (MemoryCell, error) {
+			a, err := args[0].AsInt()
+			if err != nil {
+				return nil, err
+			}
+
+			b, err := args[1].AsInt()
+			if err != nil {
+				return nil, err
+			}
+
+			if b == 0 {
+				return nil, ErrDivisionByZero
+			}
+
+			return newIntCell(a % b), nil
+		}),
+	},
+	"coalesce": {
+		check: unifying(1, -1),
+		eval: func(args []MemoryCell, _ []ColumnType, _ ColumnType) (MemoryCell, error) {
+			for _, arg := range args {
+				if arg != nil {
+					return arg, nil
+				}
+			}
+
+			return nil, nil
+		},
+	},
+	"nullif": {
+		check: unifying(2, 2),
+		eval: func(args []MemoryCell, _ []ColumnType, returns ColumnType) (MemoryCell, error) {
+			if args[0] == nil || args[1] == nil {
+				return args[0], nil
+			}
+
+			cmp, err := compareCells(args[0], args[1], returns)
+			if err != nil || cmp == 0 {
+				return nil, err
+			}
+
+			return args[0], nil
+		},
+	},
+	"greatest": {
+		check: unifying(1, -1),
+		eval: func(args []MemoryCell, _ []ColumnType, returns ColumnType) (MemoryCell, error) {
+			return extremeCell(args, returns, 1)
+		},
+	},
+	"least": {
+		check: unifying(1, -1),
+		eval: func(args []MemoryCell, _ []ColumnType, returns ColumnType) (MemoryCell, error) {
+			return extremeCell(args, returns, -1)
+		},
+	},
+	"json_extract": {
+		check: signature(JSONType, 0, JSONType, TextType),
+		eval: strict(func(args []MemoryCell) (MemoryCell, error) {
+			return jsonExtract(args[0], args[1].AsText())
+		}),
+	},
+}
+
+// ScalarFunction describes a function implemented in Go that can be
+// called from SQL once registered with RegisterFunction.
+type ScalarFunction struct {
+	Args    []ColumnType
+	Returns ColumnType
+	// Deterministic functions always return the same result for the same
+	// arguments, which lets select items that only pass them constants be
+	// evaluated once per statement instead of once per row.
+	Deterministic bool
+	// Call receives each argument as an int32 for IntType, a bool for
+	// BoolType, a string for TextType and JSONType or nil for NULL, and
+	// must return its result in the same form.
+	Call func(args []interface{}) (interface{}, error)
+}
+
+// RegisterFunction makes a Go function callable from SQL under the given
+// case-insensitive name. Built-in functions can't be replaced and each
+// name can only be registered once.
+func (mb *MemoryBackend) RegisterFunction(name string, sf ScalarFunction) error {
+	name = strings.ToLower(name)
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if mb.functionExists(name) {
+		return ErrFunctionExists
+	}
+
+	if sf.Call == nil {
+		return ErrInvalidArguments
+	}
+
+	mb.functions[name] = function{
+		check:    signature(sf.Returns, 0, sf.Args...),
+		volatile: !sf.Deterministic,
+		eval: func(args []MemoryCell, types []ColumnType, returns ColumnType) (MemoryCell, error) {
+			values := make([]interface{}, len(args))
+			for i, arg := range args {
+				value, err := cellToValue(arg, types[i])
+				if err != nil {
+					return nil, err
+				}
+
+				values[i] = value
+			}
+
+			result, err := sf.Call(values)
+			if err != nil {
+				return nil, err
+			}
+
+			return valueToCell(result, returns)
+		},
+	}
+
+	return nil
+}
+
+func (mb *MemoryBackend) lookupFunction(name string) (function, bool) {
+	if fn, ok := builtinFunctions[name]; ok {
+		return fn, true
+	}
+
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+
+	fn, ok := mb.functions[name]
+	return fn, ok
+}
+
+// functionExists reports whether name is taken by any scalar, aggregate or
+// window function. The caller must hold mb.mu.
+func (mb *MemoryBackend) functionExists(name string) bool {
+	_, isBuiltin := builtinFunctions[name]
+	_, isFunction := mb.functions[name]
+	_, isBuiltinAggregate := builtinAggregates[name]
+	_, isAggregate := mb.aggregates[name]
+	_, isWindow := windowFunctions[name]
+	return isBuiltin || isFunction || isBuiltinAggregate || isAggregate || isWindow
+}
+
+// cellToValue converts a cell into the Go value passed to functions
+// registered with RegisterFunction.
+func cellToValue(cell MemoryCell, typ ColumnType) (interface{}, error) {
+	if cell == nil {
+		return nil, nil
+	}
+
+	switch typ {
+	case IntType:
+		return cell.AsInt()
+	case BoolType:
+		return cell.AsBool()
+	}
+
+	return cell.AsText(), nil
+}
+
+// valueToCell converts a Go value into a cell of the given type. Any
+// integer type is accepted for IntType as long as it fits in an int32.
+func valueToCell(value interface{}, typ ColumnType) (MemoryCell, error) {
+	var i int64
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if typ == JSONType && !json.Valid([]byte(v)) {
+			return nil, ErrInvalidJSON
+		}
+
+		if typ == TextType || typ == JSONType {
+			return MemoryCell(v), nil
+		}
+
+		return nil, fmt.Errorf("%w: got string for %s", ErrTypeMismatch, typ)
+	case bool:
+		if typ != BoolType {
+			return nil, fmt.Errorf("%w: got bool for %s", ErrTypeMismatch, typ)
+		}
+
+		return newBoolCell(v), nil
+	case int32:
+		i = int64(v)
+	case int:
+		i = int64(v)
+	case int64:
+		i = v
+	default:
+		return nil, fmt.Errorf("%w: unsupported Go type %T", ErrTypeMismatch, value)
+	}
+
+	if typ != IntType {
+		return nil, fmt.Errorf("%w: got integer for %s", ErrTypeMismatch, typ)
+	}
+
+	if i != int64(int32(i)) {
+		return nil, ErrInvalidInteger
+	}
+
+	return newIntCell(int32(i)), nil
+}
+
+// signature returns a check for functions with fixed parameter types, the
+// last optional number of which may be omitted. NULL is accepted for any
+// parameter.
+func signature(returns ColumnType, optional int, params ...ColumnType) func([]ColumnType) (ColumnType, error) {
+	return func(args []ColumnType) (ColumnType, error) {
+		if len(args) > len(params) || len(args) < len(params)-optional {
+			return 0, fmt.Errorf("%w: expected %d arguments, got %d", ErrInvalidArguments, len(params), len(args))
+		}
+
+		for i, arg := range args {
+			if arg != params[i] && arg != NullType {
+				return 0, fmt.Errorf("%w: argument %d must be %s, got %s", ErrInvalidArguments, i+1, params[i], arg)
+			}
+		}
+
+		return returns, nil
+	}
+}
+
+// unifying returns a check for functions whose arguments must all share
+// one type, which is also the type they produce. A max of -1 allows any
+// number of arguments.
+func unifying(min, max int) func([]ColumnType) (ColumnType, error) {
+	return func(args []ColumnType) (ColumnType, error) {
+		if len(args) < min || (max >= 0 && len(args) > max) {
+			return 0, fmt.Errorf("%w: unexpected number of arguments %d", ErrInvalidArguments, len(args))
+		}
+
+		return unifyTypes(args)
+	}
+}
+
+// unifyTypes returns the single type shared by all non-NULL types given,
+// defaulting to text when every type is NULL as Postgres does.
+func unifyTypes(types []ColumnType) (ColumnType, error) {
+	unified := NullType
+	for _, typ := range types {
+		if typ == NullType {
+			continue
+		}
+
+		if unified != NullType && unified != typ {
+			return 0, fmt.Errorf("%w: %s and %s cannot be matched", ErrTypeMismatch, unified, typ)
+		}
+
+		unified = typ
+	}
+
+	if unified == NullType {
+		return TextType, nil
+	}
+
+	return unified, nil
+}
+
+// strict wraps functions that return NULL whenever any argument is NULL.
+func strict(f func(args []MemoryCell) (MemoryCell, error)) func([]MemoryCell, []ColumnType, ColumnType) (MemoryCell, error) {
+	return func(args []MemoryCell, _ []ColumnType, _ ColumnType) (MemoryCell, error) {
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			}
+		}
+
+		return f(args)
+	}
+}
+
+// evalSubstr implements substr(text, start [, count]) with Postgres
+// semantics: positions are 1-based characters and a start before the
+// beginning of the string shortens the count accordingly.
+func evalSubstr(args []MemoryCell) (MemoryCell, error) {
+	runes := []rune(args[0].AsText())
+
+	start, err := args[1].AsInt()
+	if err != nil {
+		return nil, err
+	}
+
+	end := int64(len(runes)) + 1
+	if len(args) == 3 {
+		count, err := args[2].AsInt()
+		if err != nil {
+			return nil, err
+		}
+
+		if count < 0 {
+			return nil, fmt.Errorf("%w: negative substring length", ErrInvalidArguments)
+		}
+
+		end = int64(start) + int64(count)
+	}
+
+	from := int64(start)
+	if from < 1 {
+		from = 1
+	}
+
+	if end > int64(len(runes))+1 {
+		end = int64(len(runes)) + 1
+	}
+
+	if end <= from {
+		return MemoryCell(""), nil
+	}
+
+	return MemoryCell(string(runes[from-1 : end-1])), nil
+}
+
+// evalRound implements round(int [, digits]). Integers only change when
+// rounding to a negative number of digits, e.g. round(1250, -2) is 1300.
+func evalRound(args []MemoryCell) (MemoryCell, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+
+	i, err := args[0].AsInt()
+	if err != nil {
+		return nil, err
+	}
+
+	digits, err := args[1].AsInt()
+	if err != nil {
+		return nil, err
+	}
+
+	if digits >= 0 {
+		return args[0], nil
+	}
+
+	// Every int32 rounds to zero beyond ten digits
+	if digits < -9 {
+		return newIntCell(0), nil
+	}
+
+	unit := int64(1)
+	for ; digits < 0; digits++ {
+		unit *= 10
+	}
+
+	n := int64(i)
+	half := unit / 2
+	if n < 0 {
+		n = -((-n + half) / unit * unit)
+	} else {
+		n = (n + half) / unit * unit
+	}
+
+	if n != int64(int32(n)) {
+		return nil, ErrInvalidInteger
+	}
+
+	return newIntCell(int32(n)), nil
+}
+
+// extremeCell returns the greatest (sign 1) or least (sign -1) non-NULL
+// value among args.
+func extremeCell(args []MemoryCell, typ ColumnType, sign int) (MemoryCell, error) {
+	var extreme MemoryCell
+	for _, arg := range args {
+		if arg == nil {
+			continue
+		}
+
+		if extreme == nil {
+			extreme = arg
+			continue
+		}
+
+		cmp, err := compareCells(arg, extreme, typ)
+		if err != nil {
+			return nil, err
+		}
+
+		if cmp*sign > 0 {
+			extreme = arg
+		}
+	}
+
+	return extreme, nil
+}
@@ -0,0 +1,223 @@
+package gosql
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+var (
+	ErrIndexExists  = errors.New("Index already exists")
+	ErrInvalidIndex = errors.New("Invalid index")
+)
+
+// index orders the row versions of a table version by their cell in one of
+// its columns, so that the rows starting with a prefix are found with a
+// binary search. Versions are added whether or not they can be seen, which
+// is checked when the rows they lead to are gathered.
+type index struct {
+	name   string
+	column int
+	// versions are ordered by their cell in column, NULLs first
+	versions []*rowVersion
+}
+
+// add enters a version into the index after the ones with an equal cell.
+func (idx *index) add(v *rowVersion) {
+	cell := v.cells[idx.column]
+	i := sort.Search(len(idx.versions), func(i int) bool {
+		return bytes.Compare(idx.versions[i].cells[idx.column], cell) > 0
+	})
+
+	idx.versions = append(idx.versions, nil)
+	copy(idx.versions[i+1:], idx.versions[i:])
+	idx.versions[i] = v
+}
+
+// scan returns the versions whose cell starts with prefix.
+func (idx *index) scan(prefix []byte) []*rowVersion {
+	start := sort.Search(len(idx.versions), func(i int) bool {
+		return bytes.Compare(idx.versions[i].cells[idx.column], prefix) >= 0
+	})
+
+	end := start
+	for end < len(idx.versions) && bytes.HasPrefix(idx.versions[end].cells[idx.column], prefix) {
+		end++
+	}
+
+	return idx.versions[start:end]
+}
+
+// prune drops the versions that aren't in keep anymore.
+func (idx *index) prune(keep map[*rowVersion]bool) {
+	var versions []*rowVersion
+	for _, v := range idx.versions {
+		if keep[v] {
+			versions = append(versions, v)
+		}
+	}
+
+	idx.versions = versions
+}
+
+// dropIndex removes an index from the table. The caller must hold mb.mu.
+func (t *table) dropIndex(idx *index) {
+	for i, other := range t.indexes {
+		if other == idx {
+			t.indexes = append(t.indexes[:i:i], t.indexes[i+1:]...)
+			return
+		}
+	}
+}
+
+func (mb *MemoryBackend) CreateIndex(crt *CreateIndexStatement) error {
+	return mb.autocommit(func(tx *Tx) error {
+		return tx.CreateIndex(crt)
+	})
+}
+
+// CreateIndex indexes a text column of a table, which lets a WHERE
+// condition that is or ANDs a LIKE matching the column against a constant
+// 'prefix%' pattern only go through the rows starting with that prefix.
+// Index names are unique per table. The index is used by concurrent
+// transactions as soon as it's created, which only changes how they find
+// rows, and creating it conflicts with concurrent transactions that
+// replaced the table.
+func (tx *Tx) CreateIndex(crt *CreateIndexStatement) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	return tx.fail(tx.createIndex(crt))
+}
+
+func (tx *Tx) createIndex(crt *CreateIndexStatement) error {
+	if crt.Exp.Kind != LiteralKind || crt.Exp.Literal.Kind != IdentifierKind {
+		return fmt.Errorf("%w: only columns can be indexed", ErrInvalidIndex)
+	}
+
+	mb := tx.mb
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	t, ok := mb.visibleTable(&tx.snapshot, crt.Table.Value)
+	if !ok {
+		return ErrTableDoesNotExist
+	}
+
+	if tx.snapshot.concurrent(t.xmax) {
+		return ErrSerializationFailure
+	}
+
+	column := -1
+	for i, name := range t.columns {
+		if name == crt.Exp.Literal.Value {
+			column = i
+			break
+		}
+	}
+
+	if column == -1 {
+		return ErrColumnDoesNotExist
+	}
+
+	if t.columnTypes[column] != TextType {
+		return fmt.Errorf("%w: only %s columns can be indexed, got %s", ErrInvalidIndex, TextType, t.columnTypes[column])
+	}
+
+	for _, other := range t.indexes {
+		if other.name == crt.Name.Value {
+			return ErrIndexExists
+		}
+	}
+
+	idx := &index{name: crt.Name.Value, column: column}
+	idx.versions = append(idx.versions, t.versions...)
+	sort.SliceStable(idx.versions, func(i, j int) bool {
+		return bytes.Compare(idx.versions[i].cells[column], idx.versions[j].cells[column]) < 0
+	})
+
+	t.indexes = append(t.indexes, idx)
+	tx.writes = append(tx.writes, write{index: idx, indexed: t})
+	return nil
+}
+
+// prefixCondition is a column that a WHERE condition only keeps the rows
+// of whose value starts with prefix.
+type prefixCondition struct {
+	column string
+	prefix []byte
+}
+
+// prefixConditions returns the prefix conditions of where: the LIKE
+// predicates matching a column against a constant pattern that only ends
+// with a %, when they are where itself or ANDed into it.
+func (mb *MemoryBackend) prefixConditions(where *Expression) []prefixCondition {
+	if where == nil {
+		return nil
+	}
+
+	switch where.Kind {
+	case BinaryKind:
+		if keyword(where.Binary.Op.Value) != andKeyword {
+			return nil
+		}
+
+		return append(mb.prefixConditions(&where.Binary.A), mb.prefixConditions(&where.Binary.B)...)
+	case LikeKind:
+		like := where.Like
+		column := like.Exp.Literal
+		if like.Not || keyword(like.Op.Value) != likeKeyword || like.Exp.Kind != LiteralKind || column.Kind != IdentifierKind {
+			return nil
+		}
+
+		pattern, ok := mb.constantText(&like.Pattern)
+		if !ok {
+			return nil
+		}
+
+		escape := "\\"
+		if like.Escape != nil {
+			if escape, ok = mb.constantText(like.Escape); !ok || utf8.RuneCountInString(escape) > 1 {
+				return nil
+			}
+		}
+
+		if prefix, ok := likePrefix(pattern, escape); ok && len(prefix) > 0 {
+			return []prefixCondition{{column: column.Value, prefix: prefix}}
+		}
+	}
+
+	return nil
+}
+
+// constantText returns the value of a constant text expression.
+func (mb *MemoryBackend) constantText(exp *Expression) (string, bool) {
+	if !mb.isConstant(exp) {
+		return "", false
+	}
+
+	value, _, typ, err := mb.evaluateCell(&table{}, nil, exp)
+	if err != nil || typ != TextType || value == nil {
+		return "", false
+	}
+
+	return value.AsText(), true
+}
+
+// scan returns the row versions the first of conditions that an index of
+// the table can answer leads to, in index order. The caller must hold
+// mb.mu.
+func (t *table) scan(conditions []prefixCondition) ([]*rowVersion, bool) {
+	for _, c := range conditions {
+		for _, idx := range t.indexes {
+			if t.columns[idx.column] == c.column {
+				return idx.scan(c.prefix), true
+			}
+		}
+	}
+
+	return nil, false
+}
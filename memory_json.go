@@ -0,0 +1,153 @@
+package gosql
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// jsonMember returns the raw value of the named field of a JSON object.
+// Missing fields and documents that are not objects produce a nil (NULL)
+// value rather than an error, matching the -> operator in Postgres.
+func jsonMember(doc []byte, name string) (MemoryCell, error) {
+	if doc == nil {
+		return nil, nil
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &obj); err != nil {
+		if !json.Valid(doc) {
+			return nil, ErrInvalidJSON
+		}
+
+		return nil, nil
+	}
+
+	member, ok := obj[name]
+	if !ok {
+		return nil, nil
+	}
+
+	return MemoryCell(member), nil
+}
+
+// jsonElement returns the raw value at the given index of a JSON array.
+// Negative indexes count from the end of the array.
+func jsonElement(doc []byte, index int) (MemoryCell, error) {
+	if doc == nil {
+		return nil, nil
+	}
+
+	var arr []json.RawMessage
+	if err := json.Unmarshal(doc, &arr); err != nil {
+		if !json.Valid(doc) {
+			return nil, ErrInvalidJSON
+		}
+
+		return nil, nil
+	}
+
+	if index < 0 {
+		index += len(arr)
+	}
+
+	if index < 0 || index >= len(arr) {
+		return nil, nil
+	}
+
+	return MemoryCell(arr[index]), nil
+}
+
+// jsonToText converts a raw JSON value to text as done by the ->>
+// operator: strings are unquoted, JSON null becomes NULL and everything
+// else is returned as its JSON encoding.
+func jsonToText(value []byte) (MemoryCell, error) {
+	if value == nil || string(value) == "null" {
+		return nil, nil
+	}
+
+	if value[0] == '"' {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return nil, ErrInvalidJSON
+		}
+
+		return MemoryCell(s), nil
+	}
+
+	return MemoryCell(value), nil
+}
+
+type jsonPathStep struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath parses a path of the form $.a.b[0] into its steps.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, ErrInvalidJSONPath
+	}
+
+	var steps []jsonPathStep
+	rest := path[1:]
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+
+			if end == 0 {
+				return nil, ErrInvalidJSONPath
+			}
+
+			steps = append(steps, jsonPathStep{name: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, ErrInvalidJSONPath
+			}
+
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, ErrInvalidJSONPath
+			}
+
+			steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			return nil, ErrInvalidJSONPath
+		}
+	}
+
+	return steps, nil
+}
+
+// jsonExtract follows a path like $.a.b[0] into a JSON document and
+// returns the raw value found there, or NULL if the path does not exist.
+func jsonExtract(doc []byte, path string) (MemoryCell, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := MemoryCell(doc)
+	for _, step := range steps {
+		if step.isIndex {
+			value, err = jsonElement(value, step.index)
+		} else {
+			value, err = jsonMember(value, step.name)
+		}
+
+		if err != nil || value == nil {
+			return nil, err
+		}
+	}
+
+	return value, nil
+}
@@ -0,0 +1,254 @@
+package gosql
+
+import (
+	"errors"
+)
+
+var (
+	ErrSerializationFailure = errors.New("Could not serialize access due to concurrent update")
+)
+
+// vacuumThreshold is how many row and table versions die before they are
+// cleaned up in the background.
+const vacuumThreshold = 1024
+
+// rowVersion is a version of a row, created by the transaction with ID
+// xmin and deleted by the one with ID xmax. Transaction IDs start at 1, so
+// an xmax of 0 means the version wasn't deleted and an xmin of 0 that its
+// creation was rolled back.
+type rowVersion struct {
+	xmin  uint64
+	xmax  uint64
+	cells []MemoryCell
+}
+
+// snapshot tells which transactions' changes can be seen: those of the
+// transaction taking it and of the ones that committed before it was
+// taken.
+type snapshot struct {
+	// id is the transaction the snapshot belongs to, or 0 for a single
+	// statement
+	id uint64
+	// xmin is the lowest ID of the transactions running when the snapshot
+	// was taken and xmax the first ID that wasn't assigned yet
+	xmin   uint64
+	xmax   uint64
+	active map[uint64]struct{}
+}
+
+// takeSnapshot returns a snapshot of the current state of the backend for
+// the transaction with the given ID. The caller must hold mb.mu.
+func (mb *MemoryBackend) takeSnapshot(id uint64) snapshot {
+	s := snapshot{id: id, xmin: mb.nextID, xmax: mb.nextID, active: map[uint64]struct{}{}}
+	if id != 0 && id < s.xmin {
+		s.xmin = id
+	}
+
+	for other := range mb.active {
+		if other == id {
+			continue
+		}
+
+		s.active[other] = struct{}{}
+		if other < s.xmin {
+			s.xmin = other
+		}
+	}
+
+	return s
+}
+
+// committed reports whether the changes of the transaction with the given
+// ID can be seen from the snapshot.
+func (s *snapshot) committed(id uint64) bool {
+	if id == 0 {
+		return false
+	}
+
+	if id == s.id {
+		return true
+	}
+
+	_, running := s.active[id]
+	return id < s.xmax && !running
+}
+
+// sees reports whether a version created by xmin and deleted by xmax can
+// be seen from the snapshot.
+func (s *snapshot) sees(xmin, xmax uint64) bool {
+	return s.committed(xmin) && !s.committed(xmax)
+}
+
+// concurrent reports whether a version was written by a transaction other
+// than the snapshot's own whose changes it can't see. Rolled back creations
+// don't count.
+func (s *snapshot) concurrent(id uint64) bool {
+	return id != 0 && id != s.id && !s.committed(id)
+}
+
+// visibleTable returns the version of the table with the given name that
+// can be seen from the snapshot. The caller must hold mb.mu.
+func (mb *MemoryBackend) visibleTable(s *snapshot, name string) (*table, bool) {
+	versions := mb.tables[name]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if t := versions[i]; s.sees(t.xmin, t.xmax) {
+			return t, true
+		}
+	}
+
+	return nil, false
+}
+
+// lookupTable returns the table with the given name as seen from the
+// snapshot, holding only the rows visible from it. When s is nil, the
+// table is seen as of now. When an index can answer one of the prefix
+// conditions of where, only the rows it leads to are gathered, in index
+// order, and it's still up to the caller to filter them with where.
+func (mb *MemoryBackend) lookupTable(s *snapshot, name string, where *Expression) (*table, bool) {
+	// Working out the prefixes may look up functions, which takes mb.mu
+	conditions := mb.prefixConditions(where)
+
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+
+	if s == nil {
+		now := mb.takeSnapshot(0)
+		s = &now
+	}
+
+	t, ok := mb.visibleTable(s, name)
+	if !ok {
+		return nil, false
+	}
+
+	versions, ok := t.scan(conditions)
+	if !ok {
+		versions = t.versions
+	}
+
+	visible := &table{columns: t.columns, columnTypes: t.columnTypes, rows: [][]MemoryCell{}}
+	for _, v := range versions {
+		if s.sees(v.xmin, v.xmax) {
+			visible.rows = append(visible.rows, v.cells)
+		}
+	}
+
+	return visible, true
+}
+
+// horizon returns the lowest ID of the transactions any running
+// transaction can't see the changes of. Versions deleted by a transaction
+// below it can't be seen by anyone anymore, so a transaction that is never
+// committed or rolled back keeps everything deleted after it began from
+// being cleaned up. The caller must hold mb.mu.
+func (mb *MemoryBackend) horizon() uint64 {
+	horizon := mb.nextID
+	for _, tx := range mb.active {
+		if tx.snapshot.xmin < horizon {
+			horizon = tx.snapshot.xmin
+		}
+	}
+
+	return horizon
+}
+
+// Vacuum removes the row and table versions that were deleted or rolled
+// back and can't be seen by any transaction anymore. It runs in the
+// background once enough versions died, so calling it is only needed to
+// reclaim memory right away. Tables are cleaned up one at a time, and
+// looking for their dead versions only holds up writers.
+func (mb *MemoryBackend) Vacuum() {
+	mb.vacuumMu.Lock()
+	defer mb.vacuumMu.Unlock()
+
+	mb.mu.Lock()
+	mb.dead = 0
+	var names []string
+	for name := range mb.tables {
+		names = append(names, name)
+	}
+	mb.mu.Unlock()
+
+	for _, name := range names {
+		mb.vacuumTable(name)
+	}
+}
+
+// vacuumTable removes the dead versions of the table with the given name
+// and of its rows. The caller must hold mb.vacuumMu.
+func (mb *MemoryBackend) vacuumTable(name string) {
+	// Dead versions are found while only reading, which is safe since
+	// nothing but a Vacuum removes versions, and versions don't come back
+	// to life once dead
+	mb.mu.RLock()
+	horizon := mb.horizon()
+	dead := func(xmin, xmax uint64) bool {
+		return xmin == 0 || (xmax != 0 && xmax < horizon)
+	}
+
+	versions := mb.tables[name]
+	var tables []*table
+	rows := map[*table][]*rowVersion{}
+	scanned := map[*table]int{}
+	for _, t := range versions {
+		if dead(t.xmin, t.xmax) {
+			continue
+		}
+
+		var kept []*rowVersion
+		for _, v := range t.versions {
+			if !dead(v.xmin, v.xmax) {
+				kept = append(kept, v)
+			}
+		}
+
+		tables = append(tables, t)
+		rows[t] = kept
+		scanned[t] = len(t.versions)
+	}
+	mb.mu.RUnlock()
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	// Keep what was appended in the meantime
+	for _, t := range tables {
+		t.versions = append(rows[t], t.versions[scanned[t]:]...)
+		if len(t.indexes) == 0 {
+			continue
+		}
+
+		keep := map[*rowVersion]bool{}
+		for _, v := range t.versions {
+			keep[v] = true
+		}
+		for _, idx := range t.indexes {
+			idx.prune(keep)
+		}
+	}
+	tables = append(tables, mb.tables[name][len(versions):]...)
+
+	if len(tables) == 0 {
+		delete(mb.tables, name)
+		return
+	}
+	mb.tables[name] = tables
+}
+
+// vacuumLater starts cleaning up in the background if enough versions
+// died since the last time and no cleanup is running. The caller must hold
+// mb.mu.
+func (mb *MemoryBackend) vacuumLater() {
+	if mb.dead < vacuumThreshold || mb.vacuuming {
+		return
+	}
+
+	mb.vacuuming = true
+	go func() {
+		mb.Vacuum()
+
+		mb.mu.Lock()
+		mb.vacuuming = false
+		mb.mu.Unlock()
+	}()
+}
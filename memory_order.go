@@ -0,0 +1,148 @@
+package gosql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// referencedItem resolves an ORDER BY or DISTINCT ON expression that
+// refers to a select item by its output name or its 1-based position,
+// returning nil when the expression refers to neither.
+func referencedItem(items []*SelectItem, exp *Expression) (*SelectItem, error) {
+	if exp.Kind != LiteralKind {
+		return nil, nil
+	}
+
+	switch exp.Literal.Kind {
+	case NumericKind:
+		position, err := strconv.Atoi(exp.Literal.Value)
+		if err != nil || position < 1 || position > len(items) || items[position-1].Asterisk {
+			return nil, fmt.Errorf("%w: position %s is not in select list", ErrInvalidOrderBy, exp.Literal.Value)
+		}
+
+		return items[position-1], nil
+	case IdentifierKind:
+		for _, item := range items {
+			if item.As != nil && item.As.Value == exp.Literal.Value {
+				return item, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// sortItems turns ORDER BY or DISTINCT ON expressions into select items
+// evaluated alongside the visible ones. Expressions referring to a select
+// item take on its expression and window.
+func sortItems(items []*SelectItem, exps []*Expression) ([]*SelectItem, error) {
+	var sorted []*SelectItem
+	for _, exp := range exps {
+		ref, err := referencedItem(items, exp)
+		if err != nil {
+			return nil, err
+		}
+
+		if ref != nil {
+			sorted = append(sorted, &SelectItem{Exp: ref.Exp, Over: ref.Over})
+			continue
+		}
+
+		sorted = append(sorted, &SelectItem{Exp: exp})
+	}
+
+	return sorted, nil
+}
+
+// checkDistinct rejects orderings that make the rows kept by DISTINCT
+// ambiguous. Plain DISTINCT can only order by select items, and the
+// leading ORDER BY expressions of DISTINCT ON must be its expressions.
+func checkDistinct(slct *SelectStatement, orderBy, distinctOn []*SelectItem) error {
+	if !slct.Distinct {
+		return nil
+	}
+
+	if slct.DistinctOn == nil {
+		for _, ob := range orderBy {
+			if !containsItem(*slct.Item, ob) {
+				return fmt.Errorf("%w: ORDER BY expressions must appear in select list", ErrInvalidDistinct)
+			}
+		}
+
+		return nil
+	}
+
+	for i, ob := range orderBy {
+		if i >= len(distinctOn) {
+			break
+		}
+
+		if !containsItem(distinctOn, ob) {
+			return fmt.Errorf("%w: DISTINCT ON expressions must match initial ORDER BY expressions", ErrInvalidDistinct)
+		}
+	}
+
+	return nil
+}
+
+func containsItem(items []*SelectItem, item *SelectItem) bool {
+	for _, other := range items {
+		if other.Asterisk || (other.Over == nil) != (item.Over == nil) {
+			continue
+		}
+
+		if other.Over != nil && other.Over != item.Over {
+			continue
+		}
+
+		if other.Exp.equals(item.Exp) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortRows orders rows by the values in the columns starting at offset,
+// keeping the existing order of rows that compare equal.
+func sortRows(rows [][]MemoryCell, offset int, types []ColumnType, desc []bool) error {
+	var err error
+	sort.SliceStable(rows, func(i, j int) bool {
+		a := rows[i][offset : offset+len(types)]
+		b := rows[j][offset : offset+len(types)]
+
+		cmp, cmpErr := compareSortKeys(a, b, types, desc)
+		if cmpErr != nil {
+			err = cmpErr
+		}
+
+		return cmp < 0
+	})
+
+	return err
+}
+
+// distinctRows keeps the first of the rows sharing the values in columns
+// start through end, treating NULLs as equal to each other.
+func distinctRows(rows [][]MemoryCell, start, end int) [][]MemoryCell {
+	seen := map[string]bool{}
+
+	var distinct [][]MemoryCell
+	for _, row := range rows {
+		var key strings.Builder
+		for _, value := range row[start:end] {
+			writeGroupKey(&key, value)
+		}
+
+		if seen[key.String()] {
+			continue
+		}
+
+		seen[key.String()] = true
+		distinct = append(distinct, row)
+	}
+
+	return distinct
+}
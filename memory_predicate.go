@@ -0,0 +1,299 @@
+package gosql
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// checkPredicate validates the operand types of an IN, BETWEEN, LIKE or
+// ILIKE predicate. LIKE only applies to text, the other predicates compare
+// operands that must unify to a single type.
+func (mb *MemoryBackend) checkPredicate(t *table, exp *Expression) error {
+	var types []ColumnType
+	for _, sub := range exp.subexpressions() {
+		_, typ, err := mb.expressionColumn(t, sub)
+		if err != nil {
+			return err
+		}
+
+		types = append(types, typ)
+	}
+
+	if exp.Kind == LikeKind {
+		for _, typ := range types {
+			if typ != TextType && typ != NullType {
+				return fmt.Errorf("%w: %s requires text, got %s", ErrInvalidOperands, strings.ToUpper(exp.Like.Op.Value), typ)
+			}
+		}
+
+		return nil
+	}
+
+	if _, err := unifyTypes(types); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidOperands, err)
+	}
+
+	return nil
+}
+
+func (mb *MemoryBackend) evaluatePredicateCell(t *table, row []MemoryCell, exp *Expression) (MemoryCell, string, ColumnType, error) {
+	_, err := t.checkedType(exp, func() (ColumnType, error) {
+		return BoolType, mb.checkPredicate(t, exp)
+	})
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var values []MemoryCell
+	var types []ColumnType
+	for _, sub := range exp.subexpressions() {
+		value, _, typ, err := mb.evaluateCell(t, row, sub)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		values = append(values, value)
+		types = append(types, typ)
+	}
+
+	var result MemoryCell
+	var not bool
+	switch exp.Kind {
+	case InKind:
+		result, err = evaluateIn(values, types)
+		not = exp.In.Not
+	case BetweenKind:
+		result, err = evaluateBetween(values, types)
+		not = exp.Between.Not
+	case LikeKind:
+		result, err = mb.evaluateLike(t, exp, values)
+		not = exp.Like.Not
+	}
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	if not && result != nil {
+		b, err := result.AsBool()
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		result = newBoolCell(!b)
+	}
+
+	return result, "?column?", BoolType, nil
+}
+
+// evaluateIn matches values[0] against the rest of values. The result is
+// NULL rather than false when nothing matched but the list held a NULL.
+func evaluateIn(values []MemoryCell, types []ColumnType) (MemoryCell, error) {
+	if values[0] == nil {
+		return nil, nil
+	}
+
+	typ, _ := unifyTypes(types)
+
+	var unknown bool
+	for _, value := range values[1:] {
+		if value == nil {
+			unknown = true
+			continue
+		}
+
+		cmp, err := compareCells(values[0], value, typ)
+		if err != nil {
+			return nil, err
+		}
+
+		if cmp == 0 {
+			return newBoolCell(true), nil
+		}
+	}
+
+	if unknown {
+		return nil, nil
+	}
+
+	return newBoolCell(false), nil
+}
+
+// evaluateBetween checks low <= value AND value <= high for values
+// holding value, low and high.
+func evaluateBetween(values []MemoryCell, types []ColumnType) (MemoryCell, error) {
+	typ, _ := unifyTypes(types)
+
+	bound := func(a, b MemoryCell) (MemoryCell, error) {
+		if a == nil || b == nil {
+			return nil, nil
+		}
+
+		cmp, err := compareCells(a, b, typ)
+		if err != nil {
+			return nil, err
+		}
+
+		return newBoolCell(cmp <= 0), nil
+	}
+
+	aboveLow, err := bound(values[1], values[0])
+	if err != nil {
+		return nil, err
+	}
+
+	belowHigh, err := bound(values[0], values[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return evaluateLogic(andKeyword, aboveLow, belowHigh)
+}
+
+// evaluateLike matches values[0] against the pattern in values[1] using
+// the escape character in values[2] when present, or backslash otherwise.
+func (mb *MemoryBackend) evaluateLike(t *table, exp *Expression, values []MemoryCell) (MemoryCell, error) {
+	for _, value := range values {
+		if value == nil {
+			return nil, nil
+		}
+	}
+
+	constant := func() bool {
+		return mb.isConstant(&exp.Like.Pattern) && (exp.Like.Escape == nil || mb.isConstant(exp.Like.Escape))
+	}
+	match, err := t.matcher(exp, constant, func() (matcher, error) {
+		escape := "\\"
+		if len(values) > 2 {
+			escape = values[2].AsText()
+		}
+
+		return likeMatcher(values[1].AsText(), escape, exp.Like.Op.Value == string(ilikeKeyword))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newBoolCell(match(values[0])), nil
+}
+
+// matcher reports whether text matches a LIKE pattern or a regular
+// expression.
+type matcher func(text []byte) bool
+
+// matcher returns the matcher compile builds for the pattern of exp. A
+// constant pattern is only compiled the first time exp is evaluated
+// against the table, others are compiled for every row.
+func (t *table) matcher(exp *Expression, constant func() bool, compile func() (matcher, error)) (matcher, error) {
+	cache := t.exprCache()
+	match, known := cache.matchers[exp]
+	if match != nil {
+		return match, nil
+	}
+
+	match, err := compile()
+	if err != nil {
+		return nil, err
+	}
+
+	if !known {
+		// Variable patterns are recorded as nil so that whether they are
+		// constant is only worked out once
+		if constant() {
+			cache.matchers[exp] = match
+		} else {
+			cache.matchers[exp] = nil
+		}
+	}
+
+	return match, nil
+}
+
+// likeMatcher returns a matcher for a LIKE pattern. Case sensitive
+// patterns made of a literal prefix followed by a single % are matched by
+// comparing that prefix rather than with a regular expression. In a WHERE
+// condition, an index on the column can narrow such patterns down further,
+// see prefixConditions.
+func likeMatcher(pattern, escape string, insensitive bool) (matcher, error) {
+	re, err := likeRegexp(pattern, escape, insensitive)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix, ok := likePrefix(pattern, escape); ok && !insensitive {
+		return func(text []byte) bool {
+			return bytes.HasPrefix(text, prefix)
+		}, nil
+	}
+
+	return re.Match, nil
+}
+
+// likePrefix returns the literal text a LIKE pattern starts with when the
+// only wildcard of the pattern is a % ending it.
+func likePrefix(pattern, escape string) ([]byte, bool) {
+	escapeRune, _ := utf8.DecodeRuneInString(escape)
+
+	var prefix []byte
+	escaped := false
+	for i, r := range pattern {
+		switch {
+		case escaped:
+			prefix = append(prefix, string(r)...)
+			escaped = false
+		case escape != "" && r == escapeRune:
+			escaped = true
+		case r == '%':
+			return prefix, i == len(pattern)-1
+		case r == '_':
+			return nil, false
+		default:
+			prefix = append(prefix, string(r)...)
+		}
+	}
+
+	return nil, false
+}
+
+// likeRegexp translates a LIKE pattern into an anchored regular
+// expression where % matches any sequence of characters and _ matches a
+// single character. An empty escape disables escaping.
+func likeRegexp(pattern, escape string, insensitive bool) (*regexp.Regexp, error) {
+	if utf8.RuneCountInString(escape) > 1 {
+		return nil, fmt.Errorf("%w: escape string must be empty or one character, got %q", ErrInvalidPattern, escape)
+	}
+	escapeRune, _ := utf8.DecodeRuneInString(escape)
+
+	var re strings.Builder
+	re.WriteString("(?s")
+	if insensitive {
+		re.WriteString("i")
+	}
+	re.WriteString(")^")
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case escape != "" && r == escapeRune:
+			escaped = true
+		case r == '%':
+			re.WriteString(".*")
+		case r == '_':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("%w: pattern must not end with escape character: %q", ErrInvalidPattern, pattern)
+	}
+	re.WriteString("$")
+
+	return regexp.Compile(re.String())
+}
@@ -0,0 +1,501 @@
+package gosql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var (
+	ErrInvalidParameter  = errors.New("Invalid parameter")
+	ErrUnboundParameter  = errors.New("Parameter is not bound")
+	ErrInvalidStatement  = errors.New("Prepared statements must hold exactly one statement")
+	ErrStatementNotQuery = errors.New("Statement does not return rows")
+	ErrNotPreparable     = errors.New("Only SELECT, INSERT and CREATE TABLE statements can be prepared")
+)
+
+// Parameter describes a placeholder of a prepared statement. Name is only
+// set for :name placeholders. Type is the type inferred from the context
+// the placeholder is used in, or NullType when the type of the bound Go
+// value decides.
+type Parameter struct {
+	Name string
+	Type ColumnType
+}
+
+// NamedArg is a value bound to a :name placeholder.
+type NamedArg struct {
+	Name  string
+	Value interface{}
+}
+
+// Named binds value to the :name placeholder, given without the colon.
+func Named(name string, value interface{}) NamedArg {
+	return NamedArg{Name: name, Value: value}
+}
+
+// PreparedStatement is a parsed and type checked statement that can be
+// executed many times with different parameter values.
+type PreparedStatement struct {
+	mb        *MemoryBackend
+	statement *Statement
+	params    []Parameter
+	// names maps the name of :name placeholders to their position
+	names map[string]int
+}
+
+// Prepare parses a single statement that may hold $1, ? or :name
+// placeholders and infers the type of each. A statement may only use one
+// style of placeholder; ? placeholders are numbered in source order.
+func (mb *MemoryBackend) Prepare(source string) (*PreparedStatement, error) {
+	ast, err := Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ast.Statements) != 1 {
+		return nil, ErrInvalidStatement
+	}
+
+	switch ast.Statements[0].Kind {
+	case SelectKind, InsertKind, CreateTableKind, CreateIndexKind:
+	default:
+		return nil, ErrNotPreparable
+	}
+
+	ps := &PreparedStatement{
+		mb:        mb,
+		statement: ast.Statements[0],
+		names:     map[string]int{},
+	}
+
+	if err := ps.numberParameters(); err != nil {
+		return nil, err
+	}
+
+	if err := ps.inferTypes(); err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+// Parameters returns the placeholders of the statement by position.
+func (ps *PreparedStatement) Parameters() []Parameter {
+	return append([]Parameter{}, ps.params...)
+}
+
+// Exec runs the statement with args bound to its placeholders, discarding
+// the rows of a SELECT.
+func (ps *PreparedStatement) Exec(args ...interface{}) error {
+	stmt, err := ps.bind(args)
+	if err != nil {
+		return err
+	}
+
+	switch stmt.Kind {
+	case SelectKind:
+		_, err = ps.mb.Select(stmt.SelectStatement)
+	case InsertKind:
+		err = ps.mb.Insert(stmt.InsertStatement)
+	case CreateTableKind:
+		err = ps.mb.CreateTable(stmt.CreateTableStatement)
+	case CreateIndexKind:
+		err = ps.mb.CreateIndex(stmt.CreateIndexStatement)
+	}
+
+	return err
+}
+
+// Query runs a SELECT statement with args bound to its placeholders.
+func (ps *PreparedStatement) Query(args ...interface{}) (*Results, error) {
+	if ps.statement.Kind != SelectKind {
+		return nil, ErrStatementNotQuery
+	}
+
+	stmt, err := ps.bind(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return ps.mb.Select(stmt.SelectStatement)
+}
+
+// numberParameters assigns each placeholder its position, rewriting ?
+// placeholders into the equivalent $n so that they are resolved like
+// numbered ones from then on. Numbered placeholders must not skip any
+// number, since nothing would tell the type of the ones skipped.
+func (ps *PreparedStatement) numberParameters() error {
+	style := byte(0)
+	used := map[int]bool{}
+	var err error
+	Inspect(ps.statement, func(node Node) bool {
+		exp, ok := node.(*Expression)
+		if !ok || err != nil || exp.Kind != LiteralKind || exp.Literal.Kind != ParameterKind {
+			return err == nil
+		}
+
+		lit := exp.Literal
+		if style != 0 && style != lit.Value[0] {
+			err = fmt.Errorf("%w: cannot mix placeholder styles at %s", ErrInvalidParameter, lit.Value)
+			return false
+		}
+		style = lit.Value[0]
+
+		switch style {
+		case '?':
+			ps.params = append(ps.params, Parameter{Type: NullType})
+			lit.Value = "$" + strconv.Itoa(len(ps.params))
+		case '$':
+			n, convErr := strconv.Atoi(lit.Value[1:])
+			if convErr != nil || n < 1 {
+				err = fmt.Errorf("%w: %s", ErrInvalidParameter, lit.Value)
+				return false
+			}
+
+			for len(ps.params) < n {
+				ps.params = append(ps.params, Parameter{Type: NullType})
+			}
+			used[n] = true
+		case ':':
+			name := lit.Value[1:]
+			if _, ok := ps.names[name]; !ok {
+				ps.names[name] = len(ps.params)
+				ps.params = append(ps.params, Parameter{Name: name, Type: NullType})
+			}
+		}
+
+		return true
+	})
+	if err != nil || style != '$' {
+		return err
+	}
+
+	for n := 1; n <= len(ps.params); n++ {
+		if !used[n] {
+			return fmt.Errorf("%w: could not determine type of $%d", ErrInvalidParameter, n)
+		}
+	}
+
+	return nil
+}
+
+// parameterIndex returns the position of the parameter a placeholder
+// refers to once numberParameters has run.
+func (ps *PreparedStatement) parameterIndex(lit *Token) int {
+	if lit.Value[0] == ':' {
+		return ps.names[lit.Value[1:]]
+	}
+
+	n, _ := strconv.Atoi(lit.Value[1:])
+	return n - 1
+}
+
+// inferTypes deduces the type of each placeholder from the table column
+// it is inserted into or the expression it is compared against.
+func (ps *PreparedStatement) inferTypes() error {
+	switch ps.statement.Kind {
+	case InsertKind:
+		inst := ps.statement.InsertStatement
+		t, ok := ps.mb.lookupTable(nil, inst.Table.Value, nil)
+		if !ok {
+			return ErrTableDoesNotExist
+		}
+
+		if inst.Values == nil {
+			return nil
+		}
+
+		for i, val := range *inst.Values {
+			typ := NullType
+			if i < len(t.columnTypes) {
+				typ = t.columnTypes[i]
+			}
+
+			if err := ps.infer(&table{}, val, typ); err != nil {
+				return err
+			}
+		}
+	case SelectKind:
+		slct := ps.statement.SelectStatement
+		t := &table{}
+		if slct.From != nil && slct.From.Table != nil {
+			var ok bool
+			t, ok = ps.mb.lookupTable(nil, slct.From.Table.Value, nil)
+			if !ok {
+				return ErrTableDoesNotExist
+			}
+		}
+
+		// Only the outermost expressions are visited here, infer descends
+		// into the rest itself
+		var err error
+		Inspect(slct, func(node Node) bool {
+			exp, ok := node.(*Expression)
+			if !ok {
+				return err == nil
+			}
+
+			if err == nil {
+				expected := NullType
+				if exp == slct.Where {
+					expected = BoolType
+				}
+				err = ps.infer(t, exp, expected)
+			}
+			return false
+		})
+
+		return err
+	}
+
+	return nil
+}
+
+// infer records the type expected of exp in its context if it is a
+// placeholder and otherwise descends into its subexpressions, deducing
+// what each of them is expected to be.
+func (ps *PreparedStatement) infer(t *table, exp *Expression, expected ColumnType) error {
+	// peerType is the type the known operands among exps unify to, which
+	// the placeholders among them are expected to match
+	peerType := func(exps ...*Expression) ColumnType {
+		for _, e := range exps {
+			if _, typ, err := ps.mb.expressionColumn(t, e); err == nil && typ != NullType {
+				return typ
+			}
+		}
+
+		return NullType
+	}
+
+	inferAll := func(typ ColumnType, exps ...*Expression) error {
+		for _, e := range exps {
+			if err := ps.infer(t, e, typ); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	switch exp.Kind {
+	case LiteralKind:
+		if exp.Literal.Kind != ParameterKind || expected == NullType {
+			return nil
+		}
+
+		param := &ps.params[ps.parameterIndex(exp.Literal)]
+		if param.Type != NullType && param.Type != expected {
+			return fmt.Errorf("%w: inconsistent types %s and %s deduced for %s", ErrInvalidParameter, param.Type, expected, exp.Literal.Value)
+		}
+		param.Type = expected
+	case BinaryKind:
+		a, b := &exp.Binary.A, &exp.Binary.B
+		if exp.Binary.Op.Kind == KeywordKind {
+			return inferAll(BoolType, a, b)
+		}
+
+		switch symbol(exp.Binary.Op.Value) {
+		case eqSymbol, neqSymbol, neqSymbol2, ltSymbol, lteSymbol, gtSymbol, gteSymbol:
+			return inferAll(peerType(a, b), a, b)
+		case arrowSymbol, doubleArrowSymbol:
+			if err := ps.infer(t, a, JSONType); err != nil {
+				return err
+			}
+			return ps.infer(t, b, NullType)
+		case tildeSymbol:
+			return inferAll(TextType, a, b)
+		}
+
+		return inferAll(NullType, a, b)
+	case CastKind:
+		to, err := datatypeToColumnType(exp.Cast.Datatype)
+		if err != nil {
+			return err
+		}
+
+		return ps.infer(t, &exp.Cast.Exp, to)
+	case CaseKind:
+		c := exp.Case
+		if c.Operand != nil {
+			exps := []*Expression{c.Operand}
+			for _, w := range *c.Whens {
+				exps = append(exps, w.When)
+			}
+
+			if err := inferAll(peerType(exps...), exps...); err != nil {
+				return err
+			}
+		} else {
+			for _, w := range *c.Whens {
+				if err := ps.infer(t, w.When, BoolType); err != nil {
+					return err
+				}
+			}
+		}
+
+		branches := []*Expression{}
+		for _, w := range *c.Whens {
+			branches = append(branches, w.Then)
+		}
+		if c.Else != nil {
+			branches = append(branches, c.Else)
+		}
+
+		typ := peerType(branches...)
+		if typ == NullType {
+			typ = expected
+		}
+
+		return inferAll(typ, branches...)
+	case InKind:
+		exps := append([]*Expression{&exp.In.Exp}, *exp.In.List...)
+		return inferAll(peerType(exps...), exps...)
+	case BetweenKind:
+		exps := []*Expression{&exp.Between.Exp, &exp.Between.Low, &exp.Between.High}
+		return inferAll(peerType(exps...), exps...)
+	case LikeKind:
+		return inferAll(TextType, exp.subexpressions()...)
+	}
+
+	return inferAll(NullType, exp.subexpressions()...)
+}
+
+// bind converts args into cells of the parameter types and returns a copy
+// of the statement where each placeholder is replaced by its value.
+func (ps *PreparedStatement) bind(args []interface{}) (*Statement, error) {
+	values := make([]MemoryCell, len(ps.params))
+	types := make([]ColumnType, len(ps.params))
+	bound := make([]bool, len(ps.params))
+
+	for i, arg := range args {
+		index := i
+		if named, ok := arg.(NamedArg); ok {
+			var found bool
+			index, found = ps.names[named.Name]
+			if !found {
+				return nil, fmt.Errorf("%w: no placeholder named %s", ErrInvalidParameter, named.Name)
+			}
+			arg = named.Value
+		} else if len(ps.names) > 0 {
+			return nil, fmt.Errorf("%w: argument %d must be a NamedArg", ErrInvalidParameter, i+1)
+		} else if i >= len(ps.params) {
+			return nil, fmt.Errorf("%w: expected %d arguments, got %d", ErrInvalidParameter, len(ps.params), len(args))
+		}
+
+		typ := ps.params[index].Type
+		if typ == NullType {
+			typ = valueType(arg)
+		}
+
+		cell, err := valueToCell(arg, typ)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %d: %w", index+1, err)
+		}
+
+		values[index], types[index], bound[index] = cell, typ, true
+	}
+
+	for i, ok := range bound {
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", ErrUnboundParameter, i+1)
+		}
+	}
+
+	stmt := cloneNode(ps.statement).(*Statement)
+	Rewrite(stmt, func(node Node) Node {
+		exp, ok := node.(*Expression)
+		if !ok || exp.Kind != LiteralKind || exp.Literal.Kind != ParameterKind {
+			return node
+		}
+
+		i := ps.parameterIndex(exp.Literal)
+		return boundExpression(values[i], types[i], exp.Literal.Loc)
+	})
+
+	return stmt, nil
+}
+
+// valueType returns the column type a Go value is bound as when its
+// placeholder has no inferred type.
+func valueType(value interface{}) ColumnType {
+	switch value.(type) {
+	case nil:
+		return NullType
+	case bool:
+		return BoolType
+	case string:
+		return TextType
+	}
+
+	return IntType
+}
+
+// boundExpression turns a bound value back into an expression, casting it
+// so that it keeps its parameter type wherever it is used.
+func boundExpression(cell MemoryCell, typ ColumnType, loc Location) *Expression {
+	lit := &Token{Value: string(nullKeyword), Kind: NullKind, Loc: loc}
+	if cell != nil {
+		switch typ {
+		case IntType:
+			i, _ := cell.AsInt()
+			lit = &Token{Value: strconv.Itoa(int(i)), Kind: NumericKind, Loc: loc}
+		case BoolType:
+			b, _ := cell.AsBool()
+			lit = &Token{Value: strconv.FormatBool(b), Kind: BoolKind, Loc: loc}
+		default:
+			lit = &Token{Value: cell.AsText(), Kind: StringKind, Loc: loc}
+		}
+	}
+
+	exp := &Expression{Literal: lit, Kind: LiteralKind}
+	if typ == NullType {
+		return exp
+	}
+
+	return &Expression{
+		Cast: &CastExpression{
+			Exp:      *exp,
+			Datatype: Token{Value: typ.String(), Kind: KeywordKind, Loc: loc},
+		},
+		Kind: CastKind,
+	}
+}
+
+// cloneNode deep copies an AST so that binding parameters leaves the
+// prepared statement untouched.
+func cloneNode(node Node) Node {
+	return cloneValue(reflect.ValueOf(node)).Interface().(Node)
+}
+
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.New(v.Elem().Type())
+		c.Elem().Set(cloneValue(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			c.Field(i).Set(cloneValue(v.Field(i)))
+		}
+		return c
+	}
+
+	return v
+}
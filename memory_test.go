@@ -0,0 +1,1397 @@
+package gosql
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func execute(t *testing.T, mb *MemoryBackend, source string) (*Results, error) {
+	ast, err := Parse(source)
+	assert.Nil(t, err, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var results *Results
+	var backend BackEnd = mb
+	var tx *Tx
+	for _, stmt := range ast.Statements {
+		switch stmt.Kind {
+		case CreateTableKind:
+			err = backend.CreateTable(stmt.CreateTableStatement)
+		case CreateIndexKind:
+			if tx != nil {
+				err = tx.CreateIndex(stmt.CreateIndexStatement)
+			} else {
+				err = mb.CreateIndex(stmt.CreateIndexStatement)
+			}
+		case InsertKind:
+			err = backend.Insert(stmt.InsertStatement)
+		case SelectKind:
+			results, err = backend.Select(stmt.SelectStatement)
+		case BeginKind:
+			tx = mb.Begin()
+			backend = tx
+		case CommitKind:
+			err = tx.Commit()
+			backend = mb
+		case RollbackKind:
+			err = tx.Rollback()
+			backend = mb
+		case SavepointKind:
+			err = tx.Savepoint(stmt.SavepointStatement.Name.Value)
+		case RollbackToSavepointKind:
+			err = tx.RollbackTo(stmt.SavepointStatement.Name.Value)
+		case ReleaseSavepointKind:
+			err = tx.Release(stmt.SavepointStatement.Name.Value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// resultsText renders every cell of a result as text, leaving NULLs empty.
+func resultsText(results *Results) [][]string {
+	var rows [][]string
+	for _, row := range results.Rows {
+		var cells []string
+		for i, cell := range row {
+			text := cell.AsText()
+			switch {
+			case cell.IsNull():
+			case results.Columns[i].Type == IntType:
+				n, _ := cell.AsInt()
+				text = fmt.Sprintf("%d", n)
+			case results.Columns[i].Type == BoolType:
+				b, _ := cell.(BoolCell).AsBool()
+				text = fmt.Sprintf("%t", b)
+			}
+
+			cells = append(cells, text)
+		}
+		rows = append(rows, cells)
+	}
+
+	return rows
+}
+
+func TestMemoryBackend_Insert(t *testing.T) {
+	tests := []struct {
+		source string
+		err    error
+		column string
+		value  string
+	}{
+		{
+			source: "INSERT INTO t VALUES (1, 'a', '{}')",
+			value:  "a",
+		},
+		{
+			source: "INSERT INTO t VALUES ('12', 34, '[1]')",
+			value:  "34",
+		},
+		{
+			source: "INSERT INTO t VALUES ('abc', 'a', '{}')",
+			err:    ErrTypeMismatch,
+			column: "id",
+		},
+		{
+			source: "INSERT INTO t VALUES (1, 'a', '{')",
+			err:    ErrInvalidJSON,
+			column: "doc",
+		},
+		{
+			source: "INSERT INTO t VALUES (1.5, 'a', '{}')",
+			err:    ErrInvalidInteger,
+			column: "id",
+		},
+		{
+			source: "INSERT INTO t VALUES (1, 99999999999, '{}')",
+			err:    ErrInvalidInteger,
+			column: "name",
+		},
+		{
+			source: "INSERT INTO t VALUES (1, 'a')",
+			err:    ErrMissingValues,
+		},
+	}
+
+	for _, test := range tests {
+		mb := NewMemoryBackend()
+		_, err := execute(t, mb, "CREATE TABLE t (id INT, name TEXT, doc JSON)")
+		assert.Nil(t, err)
+
+		_, err = execute(t, mb, test.source)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), test.source)
+			if test.column != "" {
+				assert.Contains(t, err.Error(), "column "+test.column+": ", test.source)
+			}
+			continue
+		}
+		assert.Nil(t, err, test.source)
+
+		results, err := execute(t, mb, "SELECT name FROM t")
+		assert.Nil(t, err, test.source)
+		assert.Equal(t, test.value, results.Rows[0][0].AsText(), test.source)
+	}
+}
+
+func TestMemoryBackend_Cast(t *testing.T) {
+	tests := []struct {
+		source string
+		err    error
+		value  string
+		typ    ColumnType
+	}{
+		{
+			source: "SELECT 12::text",
+			value:  "12",
+			typ:    TextType,
+		},
+		{
+			source: "SELECT CAST(' 42 ' AS int)::text",
+			value:  "42",
+			typ:    TextType,
+		},
+		{
+			source: "SELECT CAST('{\"a\": 1}' AS json)->'a'",
+			value:  "1",
+			typ:    JSONType,
+		},
+		{
+			source: "SELECT CAST('abc' AS int)",
+			err:    ErrInvalidCast,
+		},
+		{
+			source: "SELECT CAST('{' AS json)",
+			err:    ErrInvalidJSON,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := execute(t, NewMemoryBackend(), test.source)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), test.source)
+			continue
+		}
+		assert.Nil(t, err, test.source)
+
+		assert.Equal(t, test.typ, results.Columns[0].Type, test.source)
+		assert.Equal(t, test.value, results.Rows[0][0].AsText(), test.source)
+	}
+}
+
+func TestMemoryBackend_Functions(t *testing.T) {
+	tests := []struct {
+		source string
+		err    error
+		value  string
+		null   bool
+	}{
+		{source: "SELECT lower('AbC')", value: "abc"},
+		{source: "SELECT upper('AbC')", value: "ABC"},
+		{source: "SELECT length('héllo')::text", value: "5"},
+		{source: "SELECT substr('hello', 2, 3)", value: "ell"},
+		{source: "SELECT substr('hello', 0, 2)", value: "h"},
+		{source: "SELECT substr('hello', 4)", value: "lo"},
+		{source: "SELECT trim('  a b  ')", value: "a b"},
+		{source: "SELECT trim('xxaxx', 'x')", value: "a"},
+		{source: "SELECT replace('a-b-c', '-', '+')", value: "a+b+c"},
+		{source: "SELECT abs(CAST('-4' AS int))::text", value: "4"},
+		{source: "SELECT round(1250, CAST('-2' AS int))::text", value: "1300"},
+		{source: "SELECT round(CAST('-1250' AS int), CAST('-2' AS int))::text", value: "-1300"},
+		{source: "SELECT mod(7, 3)::text", value: "1"},
+		{source: "SELECT coalesce(NULL, 'a', 'b')", value: "a"},
+		{source: "SELECT nullif('a', 'a')", null: true},
+		{source: "SELECT nullif('a', 'b')", value: "a"},
+		{source: "SELECT greatest(3, NULL, 7, 5)::text", value: "7"},
+		{source: "SELECT least('b', 'a', 'c')", value: "a"},
+		{source: "SELECT upper(NULL)", null: true},
+		{source: "SELECT mod(1, 0)", err: ErrDivisionByZero},
+		{source: "SELECT lower(1)", err: ErrInvalidArguments},
+		{source: "SELECT substr('a')", err: ErrInvalidArguments},
+		{source: "SELECT coalesce(1, 'a')", err: ErrTypeMismatch},
+		{source: "SELECT flubbrety(1)", err: ErrFunctionNotFound},
+	}
+
+	for _, test := range tests {
+		results, err := execute(t, NewMemoryBackend(), test.source)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), test.source)
+			continue
+		}
+		assert.Nil(t, err, test.source)
+
+		assert.Equal(t, test.null, results.Rows[0][0].IsNull(), test.source)
+		assert.Equal(t, test.value, results.Rows[0][0].AsText(), test.source)
+	}
+}
+
+func TestMemoryBackend_SelectValidatesEmptyTables(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, "CREATE TABLE t (id INT, name TEXT)")
+	assert.Nil(t, err)
+
+	_, err = execute(t, mb, "SELECT lower(id) FROM t")
+	assert.True(t, errors.Is(err, ErrInvalidArguments))
+
+	results, err := execute(t, mb, "SELECT upper(name) AS n FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, "n", results.Columns[0].Name)
+	assert.Equal(t, TextType, results.Columns[0].Type)
+}
+
+func TestMemoryBackend_RegisterFunction(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, "CREATE TABLE t (id INT); INSERT INTO t VALUES (1); INSERT INTO t VALUES (2); INSERT INTO t VALUES (3);")
+	assert.Nil(t, err)
+
+	calls := 0
+	err = mb.RegisterFunction("tenant_of", ScalarFunction{
+		Args:          []ColumnType{IntType},
+		Returns:       TextType,
+		Deterministic: true,
+		Call: func(args []interface{}) (interface{}, error) {
+			calls++
+			if args[0] == nil {
+				return nil, nil
+			}
+
+			return fmt.Sprintf("tenant-%d", args[0].(int32)%2), nil
+		},
+	})
+	assert.Nil(t, err)
+
+	results, err := execute(t, mb, "SELECT tenant_of(id) FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, "tenant_of", results.Columns[0].Name)
+	assert.Equal(t, TextType, results.Columns[0].Type)
+	assert.Equal(t, "tenant-1", results.Rows[0][0].AsText())
+	assert.Equal(t, "tenant-0", results.Rows[1][0].AsText())
+	assert.Equal(t, 3, calls)
+
+	// Deterministic calls with constant arguments are only evaluated once
+	calls = 0
+	_, err = execute(t, mb, "SELECT TENANT_OF(7) FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+
+	_, err = execute(t, mb, "SELECT tenant_of('a') FROM t")
+	assert.True(t, errors.Is(err, ErrInvalidArguments))
+
+	assert.Equal(t, ErrFunctionExists, mb.RegisterFunction("Tenant_Of", ScalarFunction{}))
+	assert.Equal(t, ErrFunctionExists, mb.RegisterFunction("lower", ScalarFunction{}))
+
+	calls = 0
+	err = mb.RegisterFunction("counter", ScalarFunction{
+		Returns: IntType,
+		Call: func(args []interface{}) (interface{}, error) {
+			calls++
+			return calls, nil
+		},
+	})
+	assert.Nil(t, err)
+
+	results, err = execute(t, mb, "SELECT counter()::text FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, "3", results.Rows[2][0].AsText())
+
+	err = mb.RegisterFunction("negate", ScalarFunction{
+		Args:    []ColumnType{BoolType},
+		Returns: BoolType,
+		Call: func(args []interface{}) (interface{}, error) {
+			return !args[0].(bool), nil
+		},
+	})
+	assert.Nil(t, err)
+
+	results, err = execute(t, mb, "SELECT negate(id > 1) FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"true"}, {"false"}, {"false"}}, resultsText(results))
+}
+
+type distinctAggregate struct{}
+
+func (distinctAggregate) Init() interface{} {
+	return map[interface{}]bool{}
+}
+
+func (distinctAggregate) Step(state interface{}, args []interface{}) (interface{}, error) {
+	if args[0] != nil {
+		state.(map[interface{}]bool)[args[0]] = true
+	}
+
+	return state, nil
+}
+
+func (distinctAggregate) Merge(a, b interface{}) (interface{}, error) {
+	for k := range b.(map[interface{}]bool) {
+		a.(map[interface{}]bool)[k] = true
+	}
+
+	return a, nil
+}
+
+func (distinctAggregate) Final(state interface{}) (interface{}, error) {
+	return len(state.(map[interface{}]bool)), nil
+}
+
+func TestMemoryBackend_GroupBy(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, "CREATE TABLE t (team TEXT, score INT)")
+	assert.Nil(t, err)
+
+	// Enough rows for aggregation to span several batches
+	for i := 0; i < 2500; i++ {
+		_, err = execute(t, mb, fmt.Sprintf("INSERT INTO t VALUES ('%c', %d)", 'a'+i%3, i%10))
+		assert.Nil(t, err)
+	}
+
+	err = mb.RegisterAggregate("count_distinct", AggregateFunction{
+		Args:      []ColumnType{IntType},
+		Returns:   IntType,
+		Aggregate: distinctAggregate{},
+	})
+	assert.Nil(t, err)
+
+	results, err := execute(t, mb, "SELECT team, count(*)::text, sum(score)::text AS total, max(score)::text, count_distinct(score)::text FROM t GROUP BY team")
+	assert.Nil(t, err)
+	assert.Equal(t, "total", results.Columns[2].Name)
+	assert.Equal(t, 3, len(results.Rows))
+
+	var text [][]string
+	for _, row := range results.Rows {
+		var cells []string
+		for _, cell := range row {
+			cells = append(cells, cell.AsText())
+		}
+		text = append(text, cells)
+	}
+	assert.Equal(t, [][]string{
+		{"a", "834", "3753", "9", "10"},
+		{"b", "833", "3747", "9", "10"},
+		{"c", "833", "3750", "9", "10"},
+	}, text)
+
+	results, err = execute(t, mb, "SELECT upper(team), min(score)::text FROM t GROUP BY upper(team)")
+	assert.Nil(t, err)
+	assert.Equal(t, "A", results.Rows[0][0].AsText())
+	assert.Equal(t, "0", results.Rows[0][1].AsText())
+
+	_, err = execute(t, mb, "SELECT team, score FROM t GROUP BY team")
+	assert.True(t, errors.Is(err, ErrColumnNotGrouped))
+
+	_, err = execute(t, mb, "SELECT sum(count(*)) FROM t")
+	assert.True(t, errors.Is(err, ErrMisplacedAggregate))
+
+	assert.Equal(t, ErrFunctionExists, mb.RegisterAggregate("sum", AggregateFunction{}))
+
+	_, err = execute(t, mb, "CREATE TABLE empty (id INT)")
+	assert.Nil(t, err)
+
+	results, err = execute(t, mb, "SELECT count(*)::text, sum(id) FROM empty")
+	assert.Nil(t, err)
+	assert.Equal(t, "0", results.Rows[0][0].AsText())
+	assert.True(t, results.Rows[0][1].IsNull())
+
+	_, err = execute(t, mb, `CREATE TABLE flags (team TEXT, active BOOLEAN);
+INSERT INTO flags VALUES ('a', true);
+INSERT INTO flags VALUES ('a', false);
+INSERT INTO flags VALUES ('b', false);`)
+	assert.Nil(t, err)
+
+	results, err = execute(t, mb, "SELECT team, max(active), min(active) FROM flags GROUP BY team")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"a", "true", "false"}, {"b", "false", "false"}}, resultsText(results))
+}
+
+func TestMemoryBackend_Window(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, `CREATE TABLE t (team TEXT, score INT);
+INSERT INTO t VALUES ('b', 5);
+INSERT INTO t VALUES ('a', 3);
+INSERT INTO t VALUES ('a', 1);
+INSERT INTO t VALUES ('b', 5);
+INSERT INTO t VALUES ('a', 3);
+INSERT INTO t VALUES ('b', 2);`)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		source string
+		rows   [][]string
+	}{
+		{
+			source: "SELECT team, score::text, row_number() OVER (PARTITION BY team ORDER BY score) FROM t",
+			rows: [][]string{
+				{"a", "1", "1"}, {"a", "3", "2"}, {"a", "3", "3"},
+				{"b", "2", "1"}, {"b", "5", "2"}, {"b", "5", "3"},
+			},
+		},
+		{
+			source: "SELECT score::text, rank() OVER (ORDER BY score DESC), dense_rank() OVER (ORDER BY score DESC) FROM t",
+			rows: [][]string{
+				{"5", "1", "1"}, {"5", "1", "1"}, {"3", "3", "2"},
+				{"3", "3", "2"}, {"2", "5", "3"}, {"1", "6", "4"},
+			},
+		},
+		{
+			source: "SELECT score::text, lag(score) OVER (ORDER BY score), lead(score, 2, 0) OVER (ORDER BY score) FROM t",
+			rows: [][]string{
+				{"1", "", "3"}, {"2", "1", "3"}, {"3", "2", "5"},
+				{"3", "3", "5"}, {"5", "3", "0"}, {"5", "5", "0"},
+			},
+		},
+		{
+			// The default frame includes peers of the current row
+			source: "SELECT score::text, sum(score) OVER (ORDER BY score) FROM t",
+			rows: [][]string{
+				{"1", "1"}, {"2", "3"}, {"3", "9"},
+				{"3", "9"}, {"5", "19"}, {"5", "19"},
+			},
+		},
+		{
+			source: "SELECT score::text, sum(score) OVER (ORDER BY score ROWS BETWEEN 1 PRECEDING AND CURRENT ROW), count(*) OVER (PARTITION BY team) FROM t",
+			rows: [][]string{
+				{"1", "1", "3"}, {"2", "3", "3"}, {"3", "5", "3"},
+				{"3", "6", "3"}, {"5", "8", "3"}, {"5", "10", "3"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		results, err := execute(t, mb, test.source)
+		assert.Nil(t, err, test.source)
+
+		assert.Equal(t, test.rows, resultsText(results), test.source)
+	}
+
+	_, err = execute(t, mb, "SELECT row_number() FROM t")
+	assert.True(t, errors.Is(err, ErrWindowRequiresOver))
+
+	_, err = execute(t, mb, "SELECT lower(team) OVER () FROM t")
+	assert.True(t, errors.Is(err, ErrInvalidWindowFunction))
+
+	_, err = execute(t, mb, "SELECT sum(score) OVER (ROWS BETWEEN CURRENT ROW AND 1 PRECEDING) FROM t")
+	assert.True(t, errors.Is(err, ErrInvalidWindowFrame))
+}
+
+func TestMemoryBackend_Case(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, `CREATE TABLE t (name TEXT, score INT);
+INSERT INTO t VALUES ('a', 1);
+INSERT INTO t VALUES ('b', 5);
+INSERT INTO t VALUES ('c', NULL);`)
+	assert.Nil(t, err)
+
+	results, err := execute(t, mb, `SELECT name,
+	CASE WHEN score < 3 THEN 'low' WHEN score >= 3 THEN 'high' ELSE NULL END,
+	CASE name WHEN 'a' THEN 1 WHEN 'b' THEN 2 END AS n,
+	CASE WHEN score > 2 OR name = 'c' THEN true ELSE false END
+FROM t`)
+	assert.Nil(t, err)
+	var columns []string
+	for _, c := range results.Columns {
+		columns = append(columns, c.Name+" "+c.Type.String())
+	}
+	assert.Equal(t, []string{"name text", "case text", "n int", "case boolean"}, columns)
+	assert.Equal(t, [][]string{
+		{"a", "low", "1", "false"},
+		{"b", "high", "2", "true"},
+		{"c", "", "", "true"},
+	}, resultsText(results))
+
+	tests := []struct {
+		source string
+		err    error
+	}{
+		{"SELECT CASE WHEN true THEN 1 ELSE 'one' END", ErrTypeMismatch},
+		{"SELECT CASE WHEN 1 THEN 1 END", ErrTypeMismatch},
+		{"SELECT CASE 1 WHEN 'one' THEN 1 END", ErrTypeMismatch},
+		{"SELECT CASE WHEN NULL AND true THEN 1 ELSE 2 END::text", nil},
+		{"SELECT 1 = 'one'", ErrInvalidOperands},
+		{"SELECT 1 AND true", ErrInvalidOperands},
+	}
+
+	for _, test := range tests {
+		_, err := execute(t, mb, test.source)
+		assert.True(t, errors.Is(err, test.err), test.source)
+	}
+
+	results, err = execute(t, mb, "SELECT CASE WHEN NULL OR true THEN 'yes' END, 'yes'::boolean, false::text")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"yes", "true", "false"}}, resultsText(results))
+}
+
+func TestMemoryBackend_Predicates(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, `CREATE TABLE t (name TEXT, score INT);
+INSERT INTO t VALUES ('Alice', 1);
+INSERT INTO t VALUES ('bob', 5);
+INSERT INTO t VALUES ('a_b%', NULL);`)
+	assert.Nil(t, err)
+
+	results, err := execute(t, mb, `SELECT name,
+	score IN (1, 2, 3),
+	score NOT BETWEEN 2 AND 10,
+	name LIKE 'A%',
+	name ILIKE '_O%',
+	name LIKE 'a!_b!%' ESCAPE '!',
+	name ~ '^[a-z]+$',
+	'Alice' LIKE name,
+	'bob' ~ name
+FROM t`)
+	assert.Nil(t, err)
+	for _, c := range results.Columns[1:] {
+		assert.Equal(t, BoolType, c.Type)
+	}
+	assert.Equal(t, [][]string{
+		{"Alice", "true", "true", "true", "false", "false", "false", "true", "false"},
+		{"bob", "false", "false", "false", "true", "false", "true", "false", "true"},
+		{"a_b%", "", "", "false", "false", "true", "false", "false", "false"},
+	}, resultsText(results))
+
+	tests := []struct {
+		source string
+		result string
+		err    error
+	}{
+		{"SELECT 1 IN (2, NULL)", "", nil},
+		{"SELECT 1 IN (1, NULL)", "true", nil},
+		{"SELECT 1 NOT IN (2, 3)", "true", nil},
+		{"SELECT 'b' BETWEEN 'a' AND 'c'", "true", nil},
+		{"SELECT 5 BETWEEN NULL AND 3", "false", nil},
+		{"SELECT 'ab' LIKE 'a_'", "true", nil},
+		{"SELECT 'ab' LIKE 'a'", "false", nil},
+		{"SELECT 'a%' LIKE 'a=%' ESCAPE '='", "true", nil},
+		{"SELECT 'a\nb' LIKE 'a%b'", "true", nil},
+		{"SELECT 'ÄB' ILIKE 'ä_'", "true", nil},
+		{"SELECT NULL LIKE 'a'", "", nil},
+		{"SELECT 'ab' NOT LIKE 'b%'", "true", nil},
+		{"SELECT 'abc' LIKE 'ab%'", "true", nil},
+		{"SELECT 'Abc' LIKE 'ab%'", "false", nil},
+		{"SELECT 'Abc' ILIKE 'ab%'", "true", nil},
+		{"SELECT 'a%c' LIKE 'a\\%%'", "true", nil},
+		{"SELECT 'abc' LIKE 'a\\%%'", "false", nil},
+		{"SELECT 'a\nb' LIKE '%'", "true", nil},
+		{"SELECT 'ab' LIKE 'a%%'", "true", nil},
+		{"SELECT 'ab' LIKE 'a%b%'", "true", nil},
+		{"SELECT 1 IN ('1')", "", ErrInvalidOperands},
+		{"SELECT 1 LIKE '1'", "", ErrInvalidOperands},
+		{"SELECT 1 ~ 'a'", "", ErrInvalidOperands},
+		{"SELECT 'a' ~ '('", "", ErrInvalidPattern},
+		{"SELECT 'a' LIKE 'a' ESCAPE 'ab'", "", ErrInvalidPattern},
+		{"SELECT 'a' LIKE 'a!' ESCAPE '!'", "", ErrInvalidPattern},
+	}
+
+	for _, test := range tests {
+		results, err := execute(t, mb, test.source)
+		assert.True(t, errors.Is(err, test.err), test.source)
+		if err == nil {
+			assert.Equal(t, [][]string{{test.result}}, resultsText(results), test.source)
+		}
+	}
+}
+
+func TestMemoryBackend_Distinct(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, `CREATE TABLE t (team TEXT, name TEXT, score INT);
+INSERT INTO t VALUES ('a', 'x', 3);
+INSERT INTO t VALUES ('b', 'y', 1);
+INSERT INTO t VALUES ('a', 'z', 7);
+INSERT INTO t VALUES ('b', 'w', NULL);
+INSERT INTO t VALUES (NULL, 'v', 2);
+INSERT INTO t VALUES (NULL, 'u', 4);`)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		source string
+		rows   [][]string
+		err    error
+	}{
+		{
+			source: "SELECT DISTINCT team FROM t",
+			rows:   [][]string{{"a"}, {"b"}, {""}},
+		},
+		{
+			source: "SELECT DISTINCT team FROM t ORDER BY team DESC",
+			rows:   [][]string{{""}, {"b"}, {"a"}},
+		},
+		{
+			source: "SELECT name FROM t ORDER BY score, name",
+			rows:   [][]string{{"y"}, {"v"}, {"x"}, {"u"}, {"z"}, {"w"}},
+		},
+		{
+			source: "SELECT name AS n, score FROM t ORDER BY 2 DESC, n",
+			rows:   [][]string{{"w", ""}, {"z", "7"}, {"u", "4"}, {"x", "3"}, {"v", "2"}, {"y", "1"}},
+		},
+		{
+			source: "SELECT DISTINCT ON (team) team, name FROM t ORDER BY team, score DESC",
+			rows:   [][]string{{"a", "z"}, {"b", "w"}, {"", "u"}},
+		},
+		{
+			source: "SELECT DISTINCT ON (team) name FROM t",
+			rows:   [][]string{{"x"}, {"y"}, {"v"}},
+		},
+		{
+			source: "SELECT team, count(*)::text FROM t GROUP BY team ORDER BY count(*) DESC, team",
+			rows:   [][]string{{"a", "2"}, {"b", "2"}, {"", "2"}},
+		},
+		{
+			source: "SELECT DISTINCT team FROM t ORDER BY score",
+			err:    ErrInvalidDistinct,
+		},
+		{
+			source: "SELECT DISTINCT ON (team) team FROM t ORDER BY score",
+			err:    ErrInvalidDistinct,
+		},
+		{
+			source: "SELECT team FROM t ORDER BY 2",
+			err:    ErrInvalidOrderBy,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := execute(t, mb, test.source)
+		assert.True(t, errors.Is(err, test.err), test.source)
+		if err == nil {
+			assert.Equal(t, test.rows, resultsText(results), test.source)
+		}
+	}
+}
+
+func TestMemoryBackend_Where(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, `CREATE TABLE t (id INT, name TEXT);
+INSERT INTO t VALUES (1, 'ann');
+INSERT INTO t VALUES (2, 'bob');
+INSERT INTO t VALUES (3, NULL);
+INSERT INTO t VALUES (4, 'anna');`)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		source string
+		rows   [][]string
+		err    error
+	}{
+		{
+			source: "SELECT id FROM t WHERE name LIKE 'an%'",
+			rows:   [][]string{{"1"}, {"4"}},
+		},
+		{
+			// Rows the condition is NULL for are left out too
+			source: "SELECT id FROM t WHERE name <> 'bob'",
+			rows:   [][]string{{"1"}, {"4"}},
+		},
+		{
+			source: "SELECT name, count(*) FROM t WHERE id > 1 GROUP BY name ORDER BY name",
+			rows:   [][]string{{"anna", "1"}, {"bob", "1"}, {"", "1"}},
+		},
+		{
+			source: "SELECT id, row_number() OVER (ORDER BY id DESC) FROM t WHERE id BETWEEN 2 AND 3 ORDER BY id",
+			rows:   [][]string{{"2", "2"}, {"3", "1"}},
+		},
+		{
+			source: "SELECT id FROM t WHERE NULL",
+		},
+		{
+			source: "SELECT 1 WHERE true",
+			rows:   [][]string{{"1"}},
+		},
+		{
+			source: "SELECT id FROM t WHERE name",
+			err:    ErrTypeMismatch,
+		},
+		{
+			source: "SELECT id FROM t WHERE count(*) > 1",
+			err:    ErrMisplacedAggregate,
+		},
+		{
+			source: "SELECT id FROM t WHERE missing = 1",
+			err:    ErrColumnDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := execute(t, mb, test.source)
+		assert.True(t, errors.Is(err, test.err), test.source)
+		if err == nil {
+			assert.Equal(t, test.rows, resultsText(results), test.source)
+		}
+	}
+}
+
+func TestMemoryBackend_Index(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, `CREATE TABLE t (id INT, name TEXT);
+INSERT INTO t VALUES (1, 'bob');
+INSERT INTO t VALUES (2, 'anna');
+INSERT INTO t VALUES (3, NULL);
+CREATE INDEX by_name ON t (name);
+INSERT INTO t VALUES (4, 'ann');
+INSERT INTO t VALUES (5, 'an_');
+INSERT INTO t VALUES (6, 'Ann');`)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		source string
+		rows   [][]string
+		err    error
+	}{
+		{
+			source: "SELECT id FROM t WHERE name LIKE 'ann%' ORDER BY id",
+			rows:   [][]string{{"2"}, {"4"}},
+		},
+		{
+			source: "SELECT id FROM t WHERE id > 2 AND name LIKE lower('AN%') ORDER BY id",
+			rows:   [][]string{{"4"}, {"5"}},
+		},
+		{
+			source: "SELECT id FROM t WHERE name LIKE 'an!_%' ESCAPE '!'",
+			rows:   [][]string{{"5"}},
+		},
+		{
+			// Only prefix patterns use the index
+			source: "SELECT id FROM t WHERE name LIKE '%n_' ORDER BY id",
+			rows:   [][]string{{"2"}, {"4"}, {"5"}, {"6"}},
+		},
+		{
+			source: "SELECT id FROM t WHERE name ILIKE 'ann%' ORDER BY id",
+			rows:   [][]string{{"2"}, {"4"}, {"6"}},
+		},
+		{
+			source: "SELECT id FROM t WHERE name NOT LIKE 'an%' ORDER BY id",
+			rows:   [][]string{{"1"}, {"6"}},
+		},
+		{
+			source: "SELECT id FROM t WHERE name LIKE 'an%' OR id = 1 ORDER BY id",
+			rows:   [][]string{{"1"}, {"2"}, {"4"}, {"5"}},
+		},
+		{
+			source: "CREATE INDEX by_name ON t (name)",
+			err:    ErrIndexExists,
+		},
+		{
+			source: "CREATE INDEX by_id ON t (id)",
+			err:    ErrInvalidIndex,
+		},
+		{
+			source: "CREATE INDEX by_lower ON t (lower(name))",
+			err:    ErrInvalidIndex,
+		},
+		{
+			source: "CREATE INDEX by_missing ON t (missing)",
+			err:    ErrColumnDoesNotExist,
+		},
+		{
+			source: "CREATE INDEX by_name ON missing (name)",
+			err:    ErrTableDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := execute(t, mb, test.source)
+		assert.True(t, errors.Is(err, test.err), test.source)
+		if err == nil && test.rows != nil {
+			assert.Equal(t, test.rows, resultsText(results), test.source)
+		}
+	}
+
+	// Only the rows the index leads to are gathered
+	where := parseStatement(t, "SELECT id FROM t WHERE name LIKE 'ann%'").SelectStatement.Where
+	scanned, ok := mb.lookupTable(nil, "t", where)
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(scanned.rows))
+
+	// Rolled back rows are left out, and Vacuum drops them from the index
+	_, err = execute(t, mb, `BEGIN;
+INSERT INTO t VALUES (7, 'annie');
+ROLLBACK;`)
+	assert.Nil(t, err)
+	scanned, _ = mb.lookupTable(nil, "t", where)
+	assert.Equal(t, 2, len(scanned.rows))
+
+	mb.Vacuum()
+	versions, _ := mb.tables["t"][0].scan(mb.prefixConditions(where))
+	assert.Equal(t, 2, len(versions))
+
+	// Rolling back the creation of an index drops it
+	_, err = execute(t, mb, `BEGIN;
+CREATE INDEX by_name2 ON t (name);
+ROLLBACK;`)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(mb.tables["t"][0].indexes))
+
+	// Creating an index conflicts with concurrent transactions that
+	// replaced the table
+	tx := mb.Begin()
+	_, err = execute(t, mb, "CREATE TABLE t (id INT, name TEXT)")
+	assert.Nil(t, err)
+	err = tx.CreateIndex(parseStatement(t, "CREATE INDEX by_name2 ON t (name)").CreateIndexStatement)
+	assert.True(t, errors.Is(err, ErrSerializationFailure))
+	assert.Nil(t, tx.Rollback())
+}
+
+func TestMemoryBackend_Prepare(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, "CREATE TABLE t (name TEXT, score INT, active BOOLEAN);")
+	assert.Nil(t, err)
+
+	insert, err := mb.Prepare("INSERT INTO t VALUES (?, ?, ?)")
+	assert.Nil(t, err)
+	assert.Equal(t, []Parameter{{Type: TextType}, {Type: IntType}, {Type: BoolType}}, insert.Parameters())
+
+	assert.Nil(t, insert.Exec("it's", 3, true))
+	assert.Nil(t, insert.Exec("b", int64(7), nil))
+	assert.True(t, errors.Is(insert.Exec("c", "7", false), ErrTypeMismatch))
+	assert.True(t, errors.Is(insert.Exec("c", 7), ErrUnboundParameter))
+
+	_, err = insert.Query("c", 7, false)
+	assert.True(t, errors.Is(err, ErrStatementNotQuery))
+
+	tests := []struct {
+		source string
+		args   []interface{}
+		params []Parameter
+		rows   [][]string
+		err    error
+	}{
+		{
+			source: "SELECT name, score > $1 FROM t",
+			args:   []interface{}{5},
+			params: []Parameter{{Type: IntType}},
+			rows:   [][]string{{"it's", "false"}, {"b", "true"}},
+		},
+		{
+			source: "SELECT CASE WHEN score > :min THEN name ELSE :other END FROM t",
+			args:   []interface{}{Named("other", "low"), Named("min", 5)},
+			params: []Parameter{{Name: "min", Type: IntType}, {Name: "other", Type: TextType}},
+			rows:   [][]string{{"low"}, {"b"}},
+		},
+		{
+			source: "SELECT $2::text, $1",
+			args:   []interface{}{true, "x"},
+			params: []Parameter{{Type: NullType}, {Type: TextType}},
+			rows:   [][]string{{"x", "true"}},
+		},
+		{
+			source: "SELECT name FROM t ORDER BY score BETWEEN ? AND ?, name DESC",
+			args:   []interface{}{0, 4},
+			params: []Parameter{{Type: IntType}, {Type: IntType}},
+			rows:   [][]string{{"b"}, {"it's"}},
+		},
+		{
+			source: "SELECT name FROM t WHERE score > $1",
+			args:   []interface{}{4},
+			params: []Parameter{{Type: IntType}},
+			rows:   [][]string{{"b"}},
+		},
+		{
+			source: "SELECT name FROM t WHERE :all",
+			args:   []interface{}{Named("all", true)},
+			params: []Parameter{{Name: "all", Type: BoolType}},
+			rows:   [][]string{{"it's"}, {"b"}},
+		},
+		{
+			source: "SELECT name LIKE $1 FROM t",
+			args:   []interface{}{"it%"},
+			params: []Parameter{{Type: TextType}},
+			rows:   [][]string{{"true"}, {"false"}},
+		},
+		{
+			source: "SELECT $1 = 1 AND :a",
+			err:    ErrInvalidParameter,
+		},
+		{
+			source: "SELECT $1 = 1 OR $1 = 'a'",
+			err:    ErrInvalidParameter,
+		},
+		{
+			source: "SELECT :a",
+			args:   []interface{}{1},
+			params: []Parameter{{Name: "a", Type: NullType}},
+			err:    ErrInvalidParameter,
+		},
+		{
+			source: "SELECT $1",
+			args:   []interface{}{1.5},
+			params: []Parameter{{Type: NullType}},
+			err:    ErrTypeMismatch,
+		},
+		{
+			source: "INSERT INTO t VALUES ($1, $3)",
+			err:    ErrInvalidParameter,
+		},
+		{
+			source: "BEGIN",
+			err:    ErrNotPreparable,
+		},
+		{
+			source: "SAVEPOINT a",
+			err:    ErrNotPreparable,
+		},
+	}
+
+	for _, test := range tests {
+		stmt, err := mb.Prepare(test.source)
+		if test.params == nil {
+			assert.True(t, errors.Is(err, test.err), test.source)
+			continue
+		}
+		assert.Nil(t, err, test.source)
+		assert.Equal(t, test.params, stmt.Parameters(), test.source)
+
+		// Each run binds into a copy, leaving the statement reusable
+		for i := 0; i < 2; i++ {
+			results, err := stmt.Query(test.args...)
+			assert.True(t, errors.Is(err, test.err), test.source)
+			if err == nil {
+				assert.Equal(t, test.rows, resultsText(results), test.source)
+			}
+		}
+	}
+
+	_, err = mb.Prepare("INSERT INTO t VALUES ($1, $3)")
+	assert.EqualError(t, err, "Invalid parameter: could not determine type of $2")
+
+	_, err = execute(t, mb, "SELECT $1")
+	assert.True(t, errors.Is(err, ErrUnboundParameter))
+}
+
+func TestMemoryBackend_Transactions(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, `CREATE TABLE t (id INT);
+INSERT INTO t VALUES (1);
+BEGIN;
+CREATE TABLE u (name TEXT);
+INSERT INTO u VALUES ('a');
+INSERT INTO t VALUES (2);
+ROLLBACK;`)
+	assert.Nil(t, err)
+
+	// Neither the DDL nor the DML survived the rollback
+	_, err = execute(t, mb, "SELECT name FROM u")
+	assert.Equal(t, ErrTableDoesNotExist, err)
+	results, err := execute(t, mb, "SELECT id FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1"}}, resultsText(results))
+
+	results, err = execute(t, mb, `BEGIN;
+CREATE TABLE u (name TEXT);
+INSERT INTO u VALUES ('b');
+INSERT INTO t VALUES (3);
+COMMIT;
+SELECT id FROM t;`)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1"}, {"3"}}, resultsText(results))
+
+	// Changes are only seen inside the transaction until it commits
+	tx := mb.Begin()
+	assert.Nil(t, tx.Insert(parseStatement(t, "INSERT INTO u VALUES ('c')").InsertStatement))
+	assert.Nil(t, mb.Insert(parseStatement(t, "INSERT INTO u VALUES ('d')").InsertStatement))
+
+	results, err = tx.Select(parseStatement(t, "SELECT name FROM u").SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"b"}, {"c"}}, resultsText(results))
+
+	results, err = execute(t, mb, "SELECT name FROM u")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"b"}, {"d"}}, resultsText(results))
+
+	assert.Nil(t, tx.Commit())
+	assert.Equal(t, ErrTransactionDone, tx.Commit())
+
+	// Rows keep the order they were inserted in rather than committed
+	results, err = execute(t, mb, "SELECT name FROM u")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"b"}, {"c"}, {"d"}}, resultsText(results))
+
+	// A failing statement aborts the whole transaction
+	tx = mb.Begin()
+	assert.Nil(t, tx.Insert(parseStatement(t, "INSERT INTO t VALUES (4)").InsertStatement))
+	assert.True(t, errors.Is(tx.Insert(parseStatement(t, "INSERT INTO t VALUES ('x')").InsertStatement), ErrTypeMismatch))
+	assert.Equal(t, ErrTransactionAborted, tx.Insert(parseStatement(t, "INSERT INTO t VALUES (5)").InsertStatement))
+	assert.Equal(t, ErrTransactionAborted, tx.Commit())
+
+	results, err = execute(t, mb, "SELECT id FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1"}, {"3"}}, resultsText(results))
+}
+
+func TestMemoryBackend_TransactionSchemaChange(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, "CREATE TABLE t (a INT)")
+	assert.Nil(t, err)
+
+	// Rows of a transaction never land in a table whose columns changed
+	// since it began, as the columns can't change while it inserts rows
+	tx := mb.Begin()
+	assert.Nil(t, tx.Insert(parseStatement(t, "INSERT INTO t VALUES (1)").InsertStatement))
+	assert.Equal(t, ErrSerializationFailure, mb.CreateTable(parseStatement(t, "CREATE TABLE t (a INT, b TEXT)").CreateTableStatement))
+	assert.Nil(t, tx.Commit())
+
+	results, err := execute(t, mb, "SELECT a FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1"}}, resultsText(results))
+
+	// Nor can rows be inserted into a table replaced since it began
+	tx = mb.Begin()
+	assert.Nil(t, mb.CreateTable(parseStatement(t, "CREATE TABLE t (a INT, b TEXT)").CreateTableStatement))
+	assert.Equal(t, ErrSerializationFailure, tx.Insert(parseStatement(t, "INSERT INTO t VALUES (2)").InsertStatement))
+	assert.Equal(t, ErrTransactionAborted, tx.Commit())
+
+	results, err = execute(t, mb, "SELECT a, b FROM t")
+	assert.Nil(t, err)
+	assert.Empty(t, results.Rows)
+
+	// Tables the transaction replaced itself take its rows
+	tx = mb.Begin()
+	assert.Nil(t, tx.CreateTable(parseStatement(t, "CREATE TABLE t (a TEXT)").CreateTableStatement))
+	assert.Nil(t, tx.Insert(parseStatement(t, "INSERT INTO t VALUES ('x')").InsertStatement))
+	assert.Nil(t, tx.Commit())
+
+	results, err = execute(t, mb, "SELECT a FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"x"}}, resultsText(results))
+}
+
+func parseStatement(t *testing.T, source string) *Statement {
+	ast, err := Parse(source)
+	assert.Nil(t, err, source)
+	return ast.Statements[0]
+}
+
+func TestMemoryBackend_Savepoints(t *testing.T) {
+	mb := NewMemoryBackend()
+	results, err := execute(t, mb, `CREATE TABLE t (id INT);
+BEGIN;
+INSERT INTO t VALUES (1);
+SAVEPOINT a;
+INSERT INTO t VALUES (2);
+CREATE TABLE u (id INT);
+SAVEPOINT b;
+INSERT INTO u VALUES (3);
+ROLLBACK TO SAVEPOINT a;
+INSERT INTO t VALUES (4);
+SAVEPOINT a;
+INSERT INTO t VALUES (5);
+RELEASE a;
+ROLLBACK TO a;
+INSERT INTO t VALUES (6);
+COMMIT;
+SELECT id FROM t;`)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1"}, {"6"}}, resultsText(results))
+
+	// The table created after the savepoint was rolled back too
+	_, err = execute(t, mb, "SELECT id FROM u")
+	assert.Equal(t, ErrTableDoesNotExist, err)
+
+	// Rolling back to a savepoint resumes an aborted transaction
+	tx := mb.Begin()
+	assert.Nil(t, tx.Savepoint("s"))
+	assert.NotNil(t, tx.Insert(parseStatement(t, "INSERT INTO t VALUES ('x')").InsertStatement))
+	assert.Equal(t, ErrTransactionAborted, tx.Savepoint("t"))
+	assert.Nil(t, tx.RollbackTo("s"))
+	assert.Nil(t, tx.Insert(parseStatement(t, "INSERT INTO t VALUES (7)").InsertStatement))
+	assert.Nil(t, tx.Release("s"))
+	assert.Equal(t, ErrSavepointDoesNotExist, tx.Release("s"))
+	assert.Equal(t, ErrTransactionAborted, tx.Commit())
+
+	tx = mb.Begin()
+	assert.Nil(t, tx.Insert(parseStatement(t, "INSERT INTO t VALUES (7)").InsertStatement))
+	assert.Nil(t, tx.Savepoint("s"))
+	assert.Nil(t, tx.Insert(parseStatement(t, "INSERT INTO t VALUES (8)").InsertStatement))
+	assert.Nil(t, tx.Release("s"))
+	assert.Nil(t, tx.Commit())
+
+	results, err = execute(t, mb, "SELECT id FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1"}, {"6"}, {"7"}, {"8"}}, resultsText(results))
+}
+
+func TestMemoryBackend_Concurrency(t *testing.T) {
+	const workers = 8
+	const iterations = 100
+
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, "CREATE TABLE t (id INT, name TEXT)")
+	assert.Nil(t, err)
+
+	insert := parseStatement(t, "INSERT INTO t VALUES (1, 'a')").InsertStatement
+	count := parseStatement(t, "SELECT count(id), sum(id), max(length(name)) FROM t").SelectStatement
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(4)
+
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				assert.Nil(t, mb.Insert(insert))
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			last := int32(0)
+			for i := 0; i < iterations; i++ {
+				results, err := mb.Select(count)
+				if !assert.Nil(t, err) {
+					return
+				}
+
+				// Every select sees a consistent snapshot of the rows
+				// inserted so far
+				n, _ := results.Rows[0][0].AsInt()
+				sum, _ := results.Rows[0][1].AsInt()
+				assert.Equal(t, n, sum)
+				assert.True(t, n >= last)
+				last = n
+			}
+		}()
+
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				name := fmt.Sprintf("t_%d_%d", w, i)
+				assert.Nil(t, mb.CreateTable(parseStatement(t, "CREATE TABLE "+name+" (id INT)").CreateTableStatement))
+				assert.Nil(t, mb.Insert(parseStatement(t, "INSERT INTO "+name+" VALUES (1)").InsertStatement))
+			}
+
+			assert.Nil(t, mb.RegisterFunction(fmt.Sprintf("f_%d", w), ScalarFunction{
+				Returns: IntType,
+				Call:    func(args []interface{}) (interface{}, error) { return 1, nil },
+			}))
+		}(w)
+
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations/10; i++ {
+				tx := mb.Begin()
+				for j := 0; j < 10; j++ {
+					assert.Nil(t, tx.Insert(insert))
+				}
+				_, err := tx.Select(count)
+				assert.Nil(t, err)
+				assert.Nil(t, tx.Commit())
+
+				// Rolled back rows are cleaned up while others are busy
+				tx = mb.Begin()
+				assert.Nil(t, tx.Insert(insert))
+				assert.Nil(t, tx.Rollback())
+				mb.Vacuum()
+			}
+		}()
+	}
+	wg.Wait()
+
+	results, err := mb.Select(count)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1600", "1600", "1"}}, resultsText(results))
+
+	for w := 0; w < workers; w++ {
+		results, err := execute(t, mb, fmt.Sprintf("SELECT f_%d(), count(id) FROM t_%d_%d", w, w, iterations-1))
+		assert.Nil(t, err)
+		assert.Equal(t, [][]string{{"1", "1"}}, resultsText(results))
+	}
+}
+
+// versionCount returns how many versions of the table and of its rows the
+// backend keeps.
+func versionCount(mb *MemoryBackend, name string) (int, int) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+
+	rows := 0
+	for _, t := range mb.tables[name] {
+		rows += len(t.versions)
+	}
+
+	return len(mb.tables[name]), rows
+}
+
+func TestMemoryBackend_MVCC(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, "CREATE TABLE t (id INT); INSERT INTO t VALUES (1)")
+	assert.Nil(t, err)
+
+	createT := parseStatement(t, "CREATE TABLE t (id INT)").CreateTableStatement
+	insertT := parseStatement(t, "INSERT INTO t VALUES (2)").InsertStatement
+	selectT := parseStatement(t, "SELECT id FROM t").SelectStatement
+
+	// A transaction keeps seeing the table it began with after a
+	// concurrent one replaced it, but can't write to it anymore
+	tx1, tx2 := mb.Begin(), mb.Begin()
+	assert.Nil(t, tx1.CreateTable(createT))
+	assert.Equal(t, ErrSerializationFailure, tx2.Insert(insertT))
+	assert.Nil(t, tx1.Commit())
+	assert.Equal(t, ErrTransactionAborted, tx2.Commit())
+
+	tx1, tx2 = mb.Begin(), mb.Begin()
+	assert.Nil(t, tx1.CreateTable(createT))
+	assert.Nil(t, tx1.Commit())
+	results, err := tx2.Select(selectT)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string(nil), resultsText(results))
+	assert.Equal(t, ErrSerializationFailure, tx2.Insert(insertT))
+	assert.Nil(t, tx2.Rollback())
+
+	// Nor can a table be replaced while rows are inserted into it or it is
+	// created concurrently
+	tx1, tx2 = mb.Begin(), mb.Begin()
+	assert.Nil(t, tx1.Insert(insertT))
+	assert.Equal(t, ErrSerializationFailure, tx2.CreateTable(createT))
+	assert.Nil(t, tx1.Commit())
+	assert.Nil(t, tx2.Rollback())
+
+	createU := parseStatement(t, "CREATE TABLE u (id INT)").CreateTableStatement
+	tx1, tx2 = mb.Begin(), mb.Begin()
+	assert.Nil(t, tx1.CreateTable(createU))
+	assert.Equal(t, ErrSerializationFailure, tx2.CreateTable(createU))
+	assert.Nil(t, tx1.Rollback())
+	assert.Nil(t, tx2.Rollback())
+	assert.Nil(t, mb.CreateTable(createU))
+
+	// Rolled back rows and replaced tables are cleaned up once no
+	// transaction can see them
+	tx1 = mb.Begin()
+	assert.Nil(t, tx1.Insert(insertT))
+	assert.Nil(t, tx1.Rollback())
+
+	reader := mb.Begin()
+	assert.Nil(t, mb.CreateTable(createT))
+	assert.Nil(t, mb.Insert(insertT))
+
+	mb.Vacuum()
+	tables, rows := versionCount(mb, "t")
+	assert.Equal(t, 2, tables)
+	assert.Equal(t, 2, rows)
+
+	results, err = reader.Select(selectT)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"2"}}, resultsText(results))
+	assert.Nil(t, reader.Commit())
+
+	mb.Vacuum()
+	tables, rows = versionCount(mb, "t")
+	assert.Equal(t, 1, tables)
+	assert.Equal(t, 1, rows)
+
+	results, err = execute(t, mb, "SELECT id FROM t")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"2"}}, resultsText(results))
+
+	// Which also happens in the background once enough versions died
+	tx1 = mb.Begin()
+	for i := 0; i < vacuumThreshold; i++ {
+		assert.Nil(t, tx1.Insert(insertT))
+	}
+	assert.Nil(t, tx1.Rollback())
+
+	assert.Eventually(t, func() bool {
+		_, rows := versionCount(mb, "t")
+		return rows == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestMemoryBackend_JSON(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := execute(t, mb, `CREATE TABLE events (id INT, payload JSON, k TEXT, i INT);
+INSERT INTO events VALUES (1, '{"user": {"name": "ann", "tags": ["a", "b"]}, "n": null, "": 0}', NULL, NULL);
+INSERT INTO events VALUES (2, '[10, {"x": 20}]', 'x', 0);
+INSERT INTO events VALUES (3, NULL, 'user', 1);`)
+	assert.Nil(t, err)
+
+	// Documents are validated on insert
+	_, err = execute(t, mb, "INSERT INTO events VALUES (4, '{\"user\": }', NULL, NULL)")
+	assert.True(t, errors.Is(err, ErrInvalidJSON))
+
+	tests := []struct {
+		source string
+		typ    ColumnType
+		rows   [][]string
+		err    error
+	}{
+		{
+			source: "SELECT payload->'user'->'name' FROM events",
+			typ:    JSONType,
+			rows:   [][]string{{`"ann"`}, {""}, {""}},
+		},
+		{
+			source: "SELECT payload->'user'->>'name' FROM events",
+			typ:    TextType,
+			rows:   [][]string{{"ann"}, {""}, {""}},
+		},
+		{
+			source: "SELECT payload->'missing', payload->>'n' FROM events",
+			typ:    JSONType,
+			rows:   [][]string{{"", ""}, {"", ""}, {"", ""}},
+		},
+		{
+			source: "SELECT payload->1->>'x', payload->0 FROM events",
+			typ:    TextType,
+			rows:   [][]string{{"", ""}, {"20", "10"}, {"", ""}},
+		},
+		{
+			source: "SELECT payload->5 FROM events",
+			typ:    JSONType,
+			rows:   [][]string{{""}, {""}, {""}},
+		},
+		{
+			source: "SELECT payload->k, payload->>k FROM events",
+			typ:    JSONType,
+			rows:   [][]string{{"", ""}, {"", ""}, {"", ""}},
+		},
+		{
+			source: "SELECT payload->i FROM events",
+			typ:    JSONType,
+			rows:   [][]string{{""}, {"10"}, {""}},
+		},
+		{
+			source: "SELECT json_extract(payload, '$.user.tags[1]') FROM events",
+			typ:    JSONType,
+			rows:   [][]string{{`"b"`}, {""}, {""}},
+		},
+		{
+			source: "SELECT json_extract(payload, '$[1].x') FROM events",
+			typ:    JSONType,
+			rows:   [][]string{{""}, {"20"}, {""}},
+		},
+		{
+			source: "SELECT json_extract(payload, 'user') FROM events",
+			err:    ErrInvalidJSONPath,
+		},
+		{
+			source: "SELECT id->'a' FROM events",
+			err:    ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		results, err := execute(t, mb, test.source)
+		assert.True(t, errors.Is(err, test.err), test.source)
+		if err != nil {
+			continue
+		}
+
+		assert.Equal(t, test.typ, results.Columns[0].Type, test.source)
+		assert.Equal(t, test.rows, resultsText(results), test.source)
+	}
+
+	// NULL documents and missing members are NULL rather than JSON null
+	results, err := execute(t, mb, "SELECT payload->'user', payload->'n' FROM events")
+	assert.Nil(t, err)
+	assert.True(t, results.Rows[1][0].IsNull())
+	assert.True(t, results.Rows[2][0].IsNull())
+	assert.Equal(t, "null", results.Rows[0][1].AsText())
+}
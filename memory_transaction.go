@@ -0,0 +1,327 @@
+package gosql
+
+import (
+	"errors"
+)
+
+var (
+	ErrTransactionAborted = errors.New("Transaction is aborted, statements are ignored until ROLLBACK")
+	ErrTransactionDone    = errors.New("Transaction has already been committed or rolled back")
+
+	ErrSavepointDoesNotExist = errors.New("Savepoint does not exist")
+)
+
+// Tx is a transaction on a MemoryBackend. It sees a snapshot of the tables
+// as they were when it began along with its own changes, which other
+// transactions only see once it commits. Rollback discards them instead,
+// and savepoints mark the changes made so far so that later ones can be
+// discarded on their own. Once a statement fails the transaction is
+// aborted and can only be rolled back, either entirely or to a savepoint.
+//
+// Writing to a table that a concurrent transaction wrote to in a way that
+// conflicts fails with ErrSerializationFailure, in which case the
+// transaction can be retried. Unlike its backend, a Tx must not be used by
+// several goroutines at once.
+//
+// Every Tx must end with Commit or Rollback, even after a statement
+// failed. Until then, the row and table versions it could see can't be
+// cleaned up by Vacuum, and neither can any deleted after it began.
+type Tx struct {
+	mb       *MemoryBackend
+	id       uint64
+	snapshot snapshot
+	// writes lists the versions the transaction wrote, in order
+	writes     []write
+	savepoints []savepoint
+	aborted    bool
+	done       bool
+}
+
+// savepoint records how many writes a transaction made when it was set.
+type savepoint struct {
+	name   string
+	writes int
+}
+
+// write is a row or table version created by a transaction, a table
+// version it deleted or an index it created on a table version.
+type write struct {
+	row     *rowVersion
+	created *table
+	deleted *table
+	index   *index
+	indexed *table
+}
+
+// Begin starts a transaction, which must end with Commit or Rollback.
+func (mb *MemoryBackend) Begin() *Tx {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	tx := &Tx{mb: mb, id: mb.nextID}
+	tx.snapshot = mb.takeSnapshot(tx.id)
+	mb.nextID++
+	mb.active[tx.id] = tx
+
+	return tx
+}
+
+// check reports whether the transaction can still run statements.
+func (tx *Tx) check() error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if tx.aborted {
+		return ErrTransactionAborted
+	}
+
+	return nil
+}
+
+// fail aborts the transaction when err is not nil and returns err.
+func (tx *Tx) fail(err error) error {
+	if err != nil {
+		tx.aborted = true
+	}
+
+	return err
+}
+
+// CreateTable creates a table, replacing the one with the same name if
+// any. Replacing a table conflicts with concurrent transactions that
+// created or replaced it or inserted rows into it.
+func (tx *Tx) CreateTable(crt *CreateTableStatement) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	return tx.fail(tx.createTable(crt))
+}
+
+func (tx *Tx) createTable(crt *CreateTableStatement) error {
+	t, err := newTable(crt)
+	if err != nil {
+		return err
+	}
+
+	mb := tx.mb
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	// Only the latest version that wasn't rolled back can be alive
+	versions := mb.tables[crt.Name.Value]
+	for i := len(versions) - 1; i >= 0; i-- {
+		old := versions[i]
+		if old.xmin == 0 {
+			continue
+		}
+
+		if tx.snapshot.concurrent(old.xmin) || tx.snapshot.concurrent(old.xmax) {
+			return ErrSerializationFailure
+		}
+
+		if old.xmax == 0 {
+			for _, v := range old.versions {
+				if tx.snapshot.concurrent(v.xmin) {
+					return ErrSerializationFailure
+				}
+			}
+
+			old.xmax = tx.id
+			tx.writes = append(tx.writes, write{deleted: old})
+		}
+		break
+	}
+
+	t.xmin = tx.id
+	mb.tables[crt.Name.Value] = append(versions, t)
+	tx.writes = append(tx.writes, write{created: t})
+	return nil
+}
+
+// Insert inserts a row, which conflicts with concurrent transactions that
+// replaced the table.
+func (tx *Tx) Insert(inst *InsertStatement) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	return tx.fail(tx.insert(inst))
+}
+
+func (tx *Tx) insert(inst *InsertStatement) error {
+	mb := tx.mb
+	mb.mu.RLock()
+	t, ok := mb.visibleTable(&tx.snapshot, inst.Table.Value)
+	mb.mu.RUnlock()
+	if !ok {
+		return ErrTableDoesNotExist
+	}
+
+	if inst.Values == nil {
+		return nil
+	}
+
+	row, err := mb.evaluateRow(t, inst)
+	if err != nil {
+		return err
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if tx.snapshot.concurrent(t.xmax) {
+		return ErrSerializationFailure
+	}
+
+	v := &rowVersion{xmin: tx.id, cells: row}
+	t.versions = append(t.versions, v)
+	for _, idx := range t.indexes {
+		idx.add(v)
+	}
+	tx.writes = append(tx.writes, write{row: v})
+	return nil
+}
+
+func (tx *Tx) Select(slct *SelectStatement) (*Results, error) {
+	if err := tx.check(); err != nil {
+		return nil, err
+	}
+
+	results, err := tx.mb.query(&tx.snapshot, slct)
+	return results, tx.fail(err)
+}
+
+// Commit makes the changes of the transaction visible to the transactions
+// that begin afterwards. An aborted transaction is rolled back instead and
+// ErrTransactionAborted is returned.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if tx.aborted {
+		tx.Rollback()
+		return ErrTransactionAborted
+	}
+
+	mb := tx.mb
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	for _, w := range tx.writes {
+		if w.deleted != nil {
+			mb.dead += 1 + len(w.deleted.versions)
+		}
+	}
+
+	tx.end()
+	return nil
+}
+
+// Rollback discards every change of the transaction.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	tx.mb.mu.Lock()
+	defer tx.mb.mu.Unlock()
+
+	tx.undo(0)
+	tx.end()
+	return nil
+}
+
+// undo discards the writes made after the first n, marking the versions
+// created as rolled back and the ones deleted as alive again and dropping
+// the indexes created. The caller must hold tx.mb.mu.
+func (tx *Tx) undo(n int) {
+	for i := len(tx.writes) - 1; i >= n; i-- {
+		switch w := tx.writes[i]; {
+		case w.row != nil:
+			w.row.xmin = 0
+			tx.mb.dead++
+		case w.created != nil:
+			w.created.xmin = 0
+			tx.mb.dead++
+		case w.deleted != nil:
+			w.deleted.xmax = 0
+		case w.index != nil:
+			w.indexed.dropIndex(w.index)
+		}
+	}
+
+	tx.writes = tx.writes[:n]
+}
+
+// end finishes the transaction, cleaning up in the background if it left
+// enough dead versions behind. The caller must hold tx.mb.mu.
+func (tx *Tx) end() {
+	tx.done = true
+	delete(tx.mb.active, tx.id)
+	tx.mb.vacuumLater()
+}
+
+// Savepoint sets a savepoint with the given name. A savepoint may reuse
+// the name of an earlier one, which it hides until it's released.
+func (tx *Tx) Savepoint(name string) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	tx.savepoints = append(tx.savepoints, savepoint{name: name, writes: len(tx.writes)})
+	return nil
+}
+
+// findSavepoint returns the position of the latest savepoint with the
+// given name.
+func (tx *Tx) findSavepoint(name string) (int, error) {
+	if tx.done {
+		return 0, ErrTransactionDone
+	}
+
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if tx.savepoints[i].name == name {
+			return i, nil
+		}
+	}
+
+	return 0, ErrSavepointDoesNotExist
+}
+
+// RollbackTo discards every change made since the savepoint was set,
+// along with the savepoints set after it, and resumes an aborted
+// transaction. The savepoint itself is kept.
+func (tx *Tx) RollbackTo(name string) error {
+	i, err := tx.findSavepoint(name)
+	if err != nil {
+		return tx.fail(err)
+	}
+
+	tx.savepoints = tx.savepoints[:i+1]
+
+	tx.mb.mu.Lock()
+	tx.undo(tx.savepoints[i].writes)
+	tx.mb.mu.Unlock()
+
+	tx.aborted = false
+	return nil
+}
+
+// Release removes the savepoint and the ones set after it, keeping the
+// changes made since.
+func (tx *Tx) Release(name string) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	i, err := tx.findSavepoint(name)
+	if err != nil {
+		return tx.fail(err)
+	}
+
+	tx.savepoints = tx.savepoints[:i]
+	return nil
+}
@@ -0,0 +1,525 @@
+package gosql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// windowFunctions holds the argument checks of functions that can only be
+// called with an OVER clause. Aggregates can be called with OVER too.
+var windowFunctions = map[string]func(args []ColumnType) (ColumnType, error){
+	"row_number": signature(IntType, 0),
+	"rank":       signature(IntType, 0),
+	"dense_rank": signature(IntType, 0),
+	"lag":        checkOffsetFunction,
+	"lead":       checkOffsetFunction,
+}
+
+// checkOffsetFunction validates lag/lead(value [, offset [, default]]).
+func checkOffsetFunction(args []ColumnType) (ColumnType, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return 0, fmt.Errorf("%w: expected 1 to 3 arguments, got %d", ErrInvalidArguments, len(args))
+	}
+
+	if len(args) > 1 && args[1] != IntType && args[1] != NullType {
+		return 0, fmt.Errorf("%w: argument 2 must be %s, got %s", ErrInvalidArguments, IntType, args[1])
+	}
+
+	if len(args) == 3 {
+		return unifyTypes([]ColumnType{args[0], args[2]})
+	}
+
+	return args[0], nil
+}
+
+// hasWindows reports whether any select item has an OVER clause.
+func hasWindows(items []*SelectItem) bool {
+	for _, item := range items {
+		if item.Over != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// windowColumn determines the name and type of the column produced by a
+// select item with an OVER clause, validating its window definition.
+func (mb *MemoryBackend) windowColumn(t *table, item *SelectItem) (string, ColumnType, error) {
+	if item.Exp.Kind != CallKind {
+		return "", 0, ErrInvalidWindowFunction
+	}
+
+	call := item.Exp.Call
+	var check func([]ColumnType) (ColumnType, error)
+	if fn, ok := windowFunctions[call.Name.Value]; ok {
+		check = fn
+	} else if agg, ok := mb.lookupAggregate(call.Name.Value); ok {
+		check = agg.check
+	} else {
+		return "", 0, fmt.Errorf("%w: %s", ErrInvalidWindowFunction, call.Name.Value)
+	}
+
+	var types []ColumnType
+	for _, arg := range *call.Args {
+		calls, err := mb.collectAggregates(arg, nil, false)
+		if err != nil {
+			return "", 0, err
+		}
+
+		if len(calls) > 0 {
+			return "", 0, ErrMisplacedAggregate
+		}
+
+		_, typ, err := mb.expressionColumn(t, arg)
+		if err != nil {
+			return "", 0, err
+		}
+
+		types = append(types, typ)
+	}
+
+	typ, err := check(types)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if _, _, err := mb.windowKeyTypes(t, item.Over); err != nil {
+		return "", 0, err
+	}
+
+	if frame := item.Over.Frame; frame != nil {
+		if frame.Start.Kind == UnboundedFollowingBound || frame.End.Kind == UnboundedPrecedingBound || frame.Start.Kind > frame.End.Kind {
+			return "", 0, ErrInvalidWindowFrame
+		}
+
+		for _, bound := range []FrameBound{frame.Start, frame.End} {
+			if _, err := frameOffset(bound); err != nil {
+				return "", 0, err
+			}
+		}
+	}
+
+	return call.Name.Value, typ, nil
+}
+
+// windowKeyTypes returns the types of the PARTITION BY and ORDER BY
+// expressions of a window.
+func (mb *MemoryBackend) windowKeyTypes(t *table, over *WindowDefinition) ([]ColumnType, []ColumnType, error) {
+	var partitionTypes, orderTypes []ColumnType
+	if over.PartitionBy != nil {
+		for _, exp := range *over.PartitionBy {
+			_, typ, err := mb.expressionColumn(t, exp)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			partitionTypes = append(partitionTypes, typ)
+		}
+	}
+
+	if over.OrderBy != nil {
+		for _, item := range *over.OrderBy {
+			_, typ, err := mb.expressionColumn(t, item.Exp)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			orderTypes = append(orderTypes, typ)
+		}
+	}
+
+	return partitionTypes, orderTypes, nil
+}
+
+func frameOffset(bound FrameBound) (int, error) {
+	if bound.Offset == nil {
+		return 0, nil
+	}
+
+	offset, err := strconv.Atoi(bound.Offset.Value)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("%w: offset must be a non-negative integer, got %s", ErrInvalidWindowFrame, bound.Offset.Value)
+	}
+
+	return offset, nil
+}
+
+// windowRow is a row of the table being windowed along with the values
+// of the keys it is partitioned and sorted by.
+type windowRow struct {
+	index     int
+	partition []MemoryCell
+	order     []MemoryCell
+}
+
+// windowRows evaluates every select item with an OVER clause. The
+// resulting table holds the rows of t, sorted by the first window, with an
+// extra column for each window item, and the items are rewritten to refer
+// to those columns.
+func (mb *MemoryBackend) windowRows(t *table, items []*SelectItem) (*table, []*SelectItem, error) {
+	windowed := &table{
+		columns:     append([]string{}, t.columns...),
+		columnTypes: append([]ColumnType{}, t.columnTypes...),
+	}
+
+	var order []int
+	var values [][]MemoryCell
+	var rewritten []*SelectItem
+	for _, item := range items {
+		if item.Over == nil {
+			rewritten = append(rewritten, item)
+			continue
+		}
+
+		_, typ, err := mb.windowColumn(t, item)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sorted, results, err := mb.evaluateWindow(t, item)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if order == nil {
+			order = sorted
+		}
+
+		name := fmt.Sprintf("?window%d", len(values))
+		windowed.columns = append(windowed.columns, name)
+		windowed.columnTypes = append(windowed.columnTypes, typ)
+		values = append(values, results)
+		rewritten = append(rewritten, &SelectItem{Exp: columnReference(name), As: item.As})
+	}
+
+	for _, i := range order {
+		row := append([]MemoryCell{}, t.rows[i]...)
+		for _, results := range values {
+			row = append(row, results[i])
+		}
+
+		windowed.rows = append(windowed.rows, row)
+	}
+
+	return windowed, rewritten, nil
+}
+
+// evaluateWindow computes a window item for every row of t, returning the
+// row indexes in window order along with the value for each row index.
+func (mb *MemoryBackend) evaluateWindow(t *table, item *SelectItem) ([]int, []MemoryCell, error) {
+	over := item.Over
+	partitionTypes, orderTypes, err := mb.windowKeyTypes(t, over)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var orderDesc []bool
+	if over.OrderBy != nil {
+		for _, o := range *over.OrderBy {
+			orderDesc = append(orderDesc, o.Desc)
+		}
+	}
+
+	rows := make([]windowRow, len(t.rows))
+	for i, row := range t.rows {
+		rows[i].index = i
+		if over.PartitionBy != nil {
+			for _, exp := range *over.PartitionBy {
+				value, _, _, err := mb.evaluateCell(t, row, exp)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				rows[i].partition = append(rows[i].partition, value)
+			}
+		}
+
+		if over.OrderBy != nil {
+			for _, o := range *over.OrderBy {
+				value, _, _, err := mb.evaluateCell(t, row, o.Exp)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				rows[i].order = append(rows[i].order, value)
+			}
+		}
+	}
+
+	var sortErr error
+	sort.SliceStable(rows, func(i, j int) bool {
+		cmp, err := compareSortKeys(rows[i].partition, rows[j].partition, partitionTypes, nil)
+		if err == nil && cmp == 0 {
+			cmp, err = compareSortKeys(rows[i].order, rows[j].order, orderTypes, orderDesc)
+		}
+
+		if err != nil {
+			sortErr = err
+		}
+
+		return cmp < 0
+	})
+	if sortErr != nil {
+		return nil, nil, sortErr
+	}
+
+	results := make([]MemoryCell, len(t.rows))
+	order := make([]int, len(rows))
+	for i, row := range rows {
+		order[i] = row.index
+	}
+
+	for start := 0; start < len(rows); {
+		end := start + 1
+		for end < len(rows) {
+			cmp, err := compareSortKeys(rows[start].partition, rows[end].partition, partitionTypes, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if cmp != 0 {
+				break
+			}
+			end++
+		}
+
+		err := mb.evaluatePartition(t, item, rows[start:end], orderTypes, results)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		start = end
+	}
+
+	return order, results, nil
+}
+
+// evaluatePartition computes a window item for each row of one partition,
+// which is already sorted, storing the results by row index.
+func (mb *MemoryBackend) evaluatePartition(t *table, item *SelectItem, partition []windowRow, orderTypes []ColumnType, results []MemoryCell) error {
+	call := item.Exp.Call
+
+	// Rows are peers when they are equal according to ORDER BY, and every
+	// row is a peer when there is no ORDER BY
+	peerEnd := make([]int, len(partition))
+	peerGroups := make([]int, len(partition))
+	for start, group := 0, 0; start < len(partition); group++ {
+		end := start + 1
+		for end < len(partition) {
+			cmp, err := compareSortKeys(partition[start].order, partition[end].order, orderTypes, nil)
+			if err != nil {
+				return err
+			}
+
+			if cmp != 0 {
+				break
+			}
+			end++
+		}
+
+		for i := start; i < end; i++ {
+			peerEnd[i] = end - 1
+			peerGroups[i] = group
+		}
+		start = end
+	}
+
+	var args [][]MemoryCell
+	var argTypes []ColumnType
+	for _, arg := range *call.Args {
+		_, typ, err := mb.expressionColumn(t, arg)
+		if err != nil {
+			return err
+		}
+		argTypes = append(argTypes, typ)
+
+		var values []MemoryCell
+		for _, row := range partition {
+			value, _, _, err := mb.evaluateCell(t, t.rows[row.index], arg)
+			if err != nil {
+				return err
+			}
+
+			values = append(values, value)
+		}
+		args = append(args, values)
+	}
+
+	switch call.Name.Value {
+	case "row_number":
+		for i, row := range partition {
+			results[row.index] = newIntCell(int32(i + 1))
+		}
+	case "rank":
+		// Peers share the rank of the first of them
+		rank := 0
+		for i, row := range partition {
+			if i == 0 || peerGroups[i] != peerGroups[i-1] {
+				rank = i + 1
+			}
+
+			results[row.index] = newIntCell(int32(rank))
+		}
+	case "dense_rank":
+		for i, row := range partition {
+			results[row.index] = newIntCell(int32(peerGroups[i] + 1))
+		}
+	case "lag", "lead":
+		for i, row := range partition {
+			offset := int32(1)
+			if len(args) > 1 {
+				if args[1][i] == nil {
+					results[row.index] = nil
+					continue
+				}
+
+				var err error
+				offset, err = args[1][i].AsInt()
+				if err != nil {
+					return err
+				}
+			}
+
+			target := i - int(offset)
+			if call.Name.Value == "lead" {
+				target = i + int(offset)
+			}
+
+			if target >= 0 && target < len(partition) {
+				results[row.index] = args[0][target]
+			} else if len(args) == 3 {
+				results[row.index] = args[2][i]
+			}
+		}
+	default:
+		return mb.evaluateWindowAggregate(item, partition, peerEnd, args, argTypes, results)
+	}
+
+	return nil
+}
+
+// evaluateWindowAggregate computes an aggregate over the frame of each row
+// of a partition. Frames starting at the beginning of the partition are
+// computed incrementally from the previous row's state.
+func (mb *MemoryBackend) evaluateWindowAggregate(item *SelectItem, partition []windowRow, peerEnd []int, args [][]MemoryCell, argTypes []ColumnType, results []MemoryCell) error {
+	agg, _ := mb.lookupAggregate(item.Exp.Call.Name.Value)
+	returns, err := agg.check(argTypes)
+	if err != nil {
+		return err
+	}
+
+	step := func(state interface{}, i int) (interface{}, error) {
+		var values []interface{}
+		for j, arg := range args {
+			value, err := cellToValue(arg[i], argTypes[j])
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, value)
+		}
+
+		return agg.impl.Step(state, values)
+	}
+
+	frame := item.Over.Frame
+	state := agg.impl.Init()
+	stepped := 0
+	for i, row := range partition {
+		start, end := 0, len(partition)-1
+		if frame == nil {
+			if item.Over.OrderBy != nil {
+				end = peerEnd[i]
+			}
+		} else {
+			start = frameBoundIndex(frame.Start, i, len(partition))
+			end = frameBoundIndex(frame.End, i, len(partition))
+		}
+
+		if start < 0 {
+			start = 0
+		}
+		if end > len(partition)-1 {
+			end = len(partition) - 1
+		}
+
+		// Only frames anchored at the start of the partition can reuse the
+		// state of the previous row
+		if start != 0 {
+			state = agg.impl.Init()
+			stepped = start
+		}
+
+		for ; stepped <= end; stepped++ {
+			state, err = step(state, stepped)
+			if err != nil {
+				return err
+			}
+		}
+
+		value, err := agg.impl.Final(state)
+		if err != nil {
+			return err
+		}
+
+		results[row.index], err = valueToCell(value, returns)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// frameBoundIndex resolves a frame bound to a position within a partition
+// of n rows, relative to the current row at position i.
+func frameBoundIndex(bound FrameBound, i, n int) int {
+	offset, _ := frameOffset(bound)
+	switch bound.Kind {
+	case UnboundedPrecedingBound:
+		return 0
+	case PrecedingBound:
+		return i - offset
+	case FollowingBound:
+		return i + offset
+	case UnboundedFollowingBound:
+		return n - 1
+	}
+
+	return i
+}
+
+// compareSortKeys orders two lists of values sorted ascending, or
+// descending where desc is set. NULLs sort after every other value in
+// ascending order and before them in descending order, as in Postgres.
+func compareSortKeys(a, b []MemoryCell, types []ColumnType, desc []bool) (int, error) {
+	for i := range a {
+		var cmp int
+		switch {
+		case a[i] == nil && b[i] == nil:
+			cmp = 0
+		case a[i] == nil:
+			cmp = 1
+		case b[i] == nil:
+			cmp = -1
+		default:
+			var err error
+			cmp, err = compareCells(a[i], b[i], types[i])
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		if desc != nil && desc[i] {
+			cmp = -cmp
+		}
+
+		if cmp != 0 {
+			return cmp, nil
+		}
+	}
+
+	return 0, nil
+}
@@ -1,87 +1,145 @@
 package gosql
 
-func parseCreateTableStatement(tokens []*token, initialCursor uint, delimiter token) (*CreateTableStatement, uint, bool) {
+func (p *parser) parseCreateTableStatement(initialCursor uint, delimiter Token) (*CreateTableStatement, uint, bool) {
 	cursor := initialCursor
-	if !expectToken(tokens, cursor, tokenFromKeyword(createKeyword)) {
+	if !p.expectToken(cursor, tokenFromKeyword(createKeyword)) {
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	if !expectToken(tokens, cursor, tokenFromKeyword(tableKeyword)) {
+	if !p.expectToken(cursor, tokenFromKeyword(tableKeyword)) {
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	name, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+	name, newCursor, ok := p.parseIdentifier(cursor)
 	if !ok {
-		helpMessage(tokens, cursor, "expected table name")
+		p.syntaxError(cursor, "expected table name", "identifier")
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
-	if !expectToken(tokens, cursor, tokenFromSymbol(leftParenSymbol)) {
-		helpMessage(tokens, cursor, "expected (")
+	if !p.expectToken(cursor, tokenFromSymbol(leftParenSymbol)) {
+		p.syntaxError(cursor, "expected (", "(")
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	cols, newCursor, ok := parseColumnDefinitions(tokens, cursor, tokenFromSymbol(rightParenSymbol))
+	cols, newCursor, ok := p.parseColumnDefinitions(cursor, tokenFromSymbol(rightParenSymbol))
 	if !ok {
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
-	if !expectToken(tokens, cursor, tokenFromSymbol(rightParenSymbol)) {
-		helpMessage(tokens, cursor, "expected )")
+	if !p.expectToken(cursor, tokenFromSymbol(rightParenSymbol)) {
+		p.syntaxError(cursor, "expected )", ")")
 		return nil, initialCursor, false
 	}
 	cursor++
 
 	return &CreateTableStatement{
-		name: *name,
-		cols: cols,
+		Name: *name,
+		Cols: cols,
 	}, cursor, true
 }
 
-func parseColumnDefinitions(tokens []*token, initialCursor uint, delimiter token) (*[]*columnDefinition, uint, bool) {
+func (p *parser) parseColumnDefinitions(initialCursor uint, delimiter Token) (*[]*ColumnDefinition, uint, bool) {
 	cursor := initialCursor
 
-	cds := []*columnDefinition{}
+	cds := []*ColumnDefinition{}
 	for {
-		if cursor >= uint(len(tokens)) {
+		if cursor >= uint(len(p.tokens)) {
 			return nil, initialCursor, false
 		}
 
-		current := tokens[cursor]
+		current := p.tokens[cursor]
 		if delimiter.equals(current) {
 			break
 		}
 
 		if len(cds) > 0 {
-			if !expectToken(tokens, cursor, tokenFromSymbol(commaSymbol)) {
-				helpMessage(tokens, cursor, "Expected ,")
+			if !p.expectToken(cursor, tokenFromSymbol(commaSymbol)) {
+				p.syntaxError(cursor, "Expected comma", ",", delimiter.Value)
 				return nil, initialCursor, false
 			}
 
 			cursor++
 		}
 
-		name, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+		name, newCursor, ok := p.parseIdentifier(cursor)
 		if !ok {
-			helpMessage(tokens, cursor, "expected column name")
+			p.syntaxError(cursor, "expected column name", "identifier")
 			return nil, initialCursor, false
 		}
 		cursor = newCursor
 
-		dataType, newCursor, ok := parseToken(tokens, cursor, keywordKind)
+		dataType, newCursor, ok := p.parseToken(cursor, KeywordKind)
 		if !ok {
-			helpMessage(tokens, cursor, "expected column type")
+			p.syntaxError(cursor, "expected column type", "type")
 			return nil, initialCursor, false
 		}
 		cursor = newCursor
 
-		cds = append(cds, &columnDefinition{name: *name, datatype: *dataType})
+		cds = append(cds, &ColumnDefinition{Name: *name, Datatype: *dataType})
 	}
 
 	return &cds, cursor, true
 }
+
+func (p *parser) parseCreateIndexStatement(initialCursor uint) (*CreateIndexStatement, uint, bool) {
+	cursor := initialCursor
+	if !p.expectToken(cursor, tokenFromKeyword(createKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !p.expectToken(cursor, tokenFromKeyword(indexKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	name, newCursor, ok := p.parseIdentifier(cursor)
+	if !ok {
+		p.syntaxError(cursor, "expected index name", "identifier")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !p.expectToken(cursor, tokenFromKeyword(onKeyword)) {
+		p.syntaxError(cursor, "expected ON", "on")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	table, newCursor, ok := p.parseIdentifier(cursor)
+	if !ok {
+		p.syntaxError(cursor, "expected table name", "identifier")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !p.expectToken(cursor, tokenFromSymbol(leftParenSymbol)) {
+		p.syntaxError(cursor, "expected (", "(")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	exp, newCursor, ok := p.parseExpression(cursor, 0)
+	if !ok {
+		p.syntaxError(cursor, "expected index expression", "expression")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !p.expectToken(cursor, tokenFromSymbol(rightParenSymbol)) {
+		p.syntaxError(cursor, "expected )", ")")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &CreateIndexStatement{
+		Name:  *name,
+		Table: *table,
+		Exp:   exp,
+	}, cursor, true
+}
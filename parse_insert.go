@@ -1,52 +1,52 @@
 package gosql
 
-func parseInsertStatement(tokens []*token, initialCursor uint, delimiter token) (*InsertStatement, uint, bool) {
+func (p *parser) parseInsertStatement(initialCursor uint, delimiter Token) (*InsertStatement, uint, bool) {
 	cursor := initialCursor
-	if !expectToken(tokens, cursor, tokenFromKeyword(insertKeyword)) {
+	if !p.expectToken(cursor, tokenFromKeyword(insertKeyword)) {
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	if !expectToken(tokens, cursor, tokenFromKeyword(intoKeyword)) {
-		helpMessage(tokens, cursor, "expected INTO")
+	if !p.expectToken(cursor, tokenFromKeyword(intoKeyword)) {
+		p.syntaxError(cursor, "expected INTO", "INTO")
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	table, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+	table, newCursor, ok := p.parseIdentifier(cursor)
 	if !ok {
-		helpMessage(tokens, cursor, "expected table name")
+		p.syntaxError(cursor, "expected table name", "identifier")
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
-	if !expectToken(tokens, cursor, tokenFromKeyword(valuesKeyword)) {
-		helpMessage(tokens, cursor, "expected VALUES")
+	if !p.expectToken(cursor, tokenFromKeyword(valuesKeyword)) {
+		p.syntaxError(cursor, "expected VALUES", "VALUES")
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	if !expectToken(tokens, cursor, tokenFromSymbol(leftParenSymbol)) {
-		helpMessage(tokens, cursor, "expected (")
+	if !p.expectToken(cursor, tokenFromSymbol(leftParenSymbol)) {
+		p.syntaxError(cursor, "expected (", "(")
 		return nil, initialCursor, false
 	}
 	cursor++
 
-	values, newCursor, ok := parseExpressions(tokens, cursor, tokenFromSymbol(rightParenSymbol))
+	values, newCursor, ok := p.parseExpressions(cursor, tokenFromSymbol(rightParenSymbol))
 	if !ok {
-		helpMessage(tokens, cursor, "expected one or more comma separated values")
+		p.syntaxError(cursor, "expected one or more comma separated values", "expression")
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
-	if !expectToken(tokens, cursor, tokenFromSymbol(rightParenSymbol)) {
-		helpMessage(tokens, cursor, "expected )")
+	if !p.expectToken(cursor, tokenFromSymbol(rightParenSymbol)) {
+		p.syntaxError(cursor, "expected )", ")")
 		return nil, initialCursor, false
 	}
 	cursor++
 
 	return &InsertStatement{
-		table:  *table,
-		values: values,
+		Table:  *table,
+		Values: values,
 	}, cursor, true
 }
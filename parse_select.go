@@ -1,52 +1,133 @@
 package gosql
 
-// SELECT [ident [, ...]] [FROM ident]
-func parseSelectStatement(tokens []*token, initialCursor uint, delimiter token) (*SelectStatement, uint, bool) {
+// SELECT [DISTINCT [ON ( expression [, ...] )]] [ident [, ...]] [FROM ident]
+// [WHERE expression] [GROUP BY expression [, ...]]
+// [ORDER BY expression [ASC | DESC] [, ...]]
+func (p *parser) parseSelectStatement(initialCursor uint, delimiter Token) (*SelectStatement, uint, bool) {
 	cursor := initialCursor
-	if !expectToken(tokens, cursor, tokenFromKeyword(selectKeyword)) {
+	if !p.expectToken(cursor, tokenFromKeyword(selectKeyword)) {
 		return nil, initialCursor, false
 	}
 	cursor++
 
 	slct := SelectStatement{}
 
-	item, newCursor, ok := parseSelectItem(tokens, cursor, []token{tokenFromKeyword(fromKeyword), delimiter})
+	if p.expectToken(cursor, tokenFromKeyword(distinctKeyword)) {
+		cursor++
+		slct.Distinct = true
+
+		if p.expectToken(cursor, tokenFromKeyword(onKeyword)) {
+			cursor++
+
+			if !p.expectToken(cursor, tokenFromSymbol(leftParenSymbol)) {
+				p.syntaxError(cursor, "Expected ( after DISTINCT ON", "(")
+				return nil, initialCursor, false
+			}
+			cursor++
+
+			distinctOn, newCursor, ok := p.parseExpressionList(cursor)
+			if !ok {
+				return nil, initialCursor, false
+			}
+			cursor = newCursor
+
+			if !p.expectToken(cursor, tokenFromSymbol(rightParenSymbol)) {
+				p.syntaxError(cursor, "Expected closing paren", ")")
+				return nil, initialCursor, false
+			}
+			cursor++
+
+			slct.DistinctOn = distinctOn
+		}
+	}
+
+	delimiters := []Token{
+		tokenFromKeyword(fromKeyword),
+		tokenFromKeyword(whereKeyword),
+		tokenFromKeyword(groupKeyword),
+		tokenFromKeyword(orderKeyword),
+		delimiter,
+	}
+	item, newCursor, ok := p.parseSelectItem(cursor, delimiters)
 	if !ok {
 		return nil, initialCursor, false
 	}
 
-	slct.item = item
+	slct.Item = item
 	cursor = newCursor
 
-	if expectToken(tokens, cursor, tokenFromKeyword(fromKeyword)) {
+	if p.expectToken(cursor, tokenFromKeyword(fromKeyword)) {
+		cursor++
+
+		from, newCursor, ok := p.parseFromItem(cursor, delimiter)
+		if !ok {
+			p.syntaxError(cursor, "Expected FROM item", "identifier")
+			return nil, initialCursor, false
+		}
+
+		slct.From = from
+		cursor = newCursor
+	}
+
+	if p.expectToken(cursor, tokenFromKeyword(whereKeyword)) {
+		cursor++
+
+		where, newCursor, ok := p.parseExpression(cursor, 0)
+		if !ok {
+			p.syntaxError(cursor, "Expected WHERE condition", "expression")
+			return nil, initialCursor, false
+		}
+
+		slct.Where = where
+		cursor = newCursor
+	}
+
+	if p.expectToken(cursor, tokenFromKeyword(groupKeyword)) {
+		cursor++
+
+		if !p.expectToken(cursor, tokenFromKeyword(byKeyword)) {
+			p.syntaxError(cursor, "Expected BY after GROUP", "BY")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		groupBy, newCursor, ok := p.parseExpressionList(cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		slct.GroupBy = groupBy
+		cursor = newCursor
+	}
+
+	if p.expectToken(cursor, tokenFromKeyword(orderKeyword)) {
 		cursor++
 
-		from, newCursor, ok := parseFromItem(tokens, cursor, delimiter)
+		orderBy, newCursor, ok := p.parseOrderBy(cursor)
 		if !ok {
-			helpMessage(tokens, cursor, "Expected FROM item")
 			return nil, initialCursor, false
 		}
 
-		slct.from = from
+		slct.OrderBy = orderBy
 		cursor = newCursor
 	}
 
 	return &slct, cursor, true
 }
 
-// expression [AS ident] [, ...]
-func parseSelectItem(tokens []*token, initialCursor uint, delimiters []token) (*[]*selectItem, uint, bool) {
+// expression [OVER (window)] [AS ident] [, ...]
+func (p *parser) parseSelectItem(initialCursor uint, delimiters []Token) (*[]*SelectItem, uint, bool) {
 	cursor := initialCursor
 
-	s := []*selectItem{}
+	s := []*SelectItem{}
 
 outer:
 	for {
-		if cursor >= uint(len(tokens)) {
+		if cursor >= uint(len(p.tokens)) {
 			return nil, initialCursor, false
 		}
 
-		current := tokens[cursor]
+		current := p.tokens[cursor]
 		for _, delimiter := range delimiters {
 			if delimiter.equals(current) {
 				break outer
@@ -54,53 +135,249 @@ outer:
 		}
 
 		if len(s) > 0 {
-			if !expectToken(tokens, cursor, tokenFromSymbol(commaSymbol)) {
-				helpMessage(tokens, cursor, "Expected ,")
+			if !p.expectToken(cursor, tokenFromSymbol(commaSymbol)) {
+				p.syntaxError(cursor, "Expected ,", ",")
 				return nil, initialCursor, false
 			}
 
 			cursor++
 		}
 
-		var si selectItem
-		if expectToken(tokens, cursor, tokenFromSymbol(asteriskSymbol)) {
-			si = selectItem{asterisk: true}
+		var si SelectItem
+		if p.expectToken(cursor, tokenFromSymbol(asteriskSymbol)) {
+			si = SelectItem{Asterisk: true}
 			cursor++
 			s = append(s, &si)
 			continue
 		}
 
-		exp, newCursor, ok := parseExpression(tokens, cursor, tokenFromSymbol(commaSymbol))
+		exp, newCursor, ok := p.parseExpression(cursor, 0)
 		if !ok {
-			helpMessage(tokens, cursor, "Expected expression")
+			p.syntaxError(cursor, "Expected expression", "expression")
 			return nil, initialCursor, false
 		}
 
 		cursor = newCursor
-		si.exp = exp
+		si.Exp = exp
+
+		if p.expectToken(cursor, tokenFromKeyword(overKeyword)) {
+			cursor++
+
+			over, newCursor, ok := p.parseWindowDefinition(cursor)
+			if !ok {
+				return nil, initialCursor, false
+			}
+
+			cursor = newCursor
+			si.Over = over
+		}
 
-		if expectToken(tokens, cursor, tokenFromKeyword(asKeyword)) {
+		if p.expectToken(cursor, tokenFromKeyword(asKeyword)) {
 			cursor++
 
-			id, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+			id, newCursor, ok := p.parseIdentifier(cursor)
 			if !ok {
-				helpMessage(tokens, cursor, "Expected identifier after AS")
+				p.syntaxError(cursor, "Expected identifier after AS", "identifier")
 				return nil, initialCursor, false
 			}
 
 			cursor = newCursor
-			si.as = id
+			si.As = id
 		}
 		s = append(s, &si)
 	}
 	return &s, cursor, true
 }
 
-func parseFromItem(tokens []*token, initialCursor uint, _ token) (*fromItem, uint, bool) {
-	ident, newCursor, ok := parseToken(tokens, initialCursor, identifierKind)
+func (p *parser) parseFromItem(initialCursor uint, _ Token) (*FromItem, uint, bool) {
+	ident, newCursor, ok := p.parseIdentifier(initialCursor)
 	if !ok {
 		return nil, initialCursor, false
 	}
 
-	return &fromItem{table: ident}, newCursor, true
+	return &FromItem{Table: ident}, newCursor, true
+}
+
+// ( [PARTITION BY expression [, ...]] [ORDER BY expression [ASC | DESC] [, ...]] [ROWS frame] )
+func (p *parser) parseWindowDefinition(initialCursor uint) (*WindowDefinition, uint, bool) {
+	cursor := initialCursor
+
+	if !p.expectToken(cursor, tokenFromSymbol(leftParenSymbol)) {
+		p.syntaxError(cursor, "Expected ( after OVER", "(")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	window := WindowDefinition{}
+
+	if p.expectToken(cursor, tokenFromKeyword(partitionKeyword)) {
+		cursor++
+
+		if !p.expectToken(cursor, tokenFromKeyword(byKeyword)) {
+			p.syntaxError(cursor, "Expected BY after PARTITION", "BY")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		partitionBy, newCursor, ok := p.parseExpressionList(cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		window.PartitionBy = partitionBy
+		cursor = newCursor
+	}
+
+	if p.expectToken(cursor, tokenFromKeyword(orderKeyword)) {
+		cursor++
+
+		orderBy, newCursor, ok := p.parseOrderBy(cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		window.OrderBy = orderBy
+		cursor = newCursor
+	}
+
+	if p.expectToken(cursor, tokenFromKeyword(rowsKeyword)) {
+		cursor++
+
+		frame, newCursor, ok := p.parseWindowFrame(cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		window.Frame = frame
+		cursor = newCursor
+	}
+
+	if !p.expectToken(cursor, tokenFromSymbol(rightParenSymbol)) {
+		p.syntaxError(cursor, "Expected ) to end window definition", ")")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &window, cursor, true
+}
+
+// BY expression [ASC | DESC] [, ...]
+func (p *parser) parseOrderBy(initialCursor uint) (*[]*OrderByItem, uint, bool) {
+	cursor := initialCursor
+
+	if !p.expectToken(cursor, tokenFromKeyword(byKeyword)) {
+		p.syntaxError(cursor, "Expected BY after ORDER", "BY")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	items := []*OrderByItem{}
+	for {
+		exp, newCursor, ok := p.parseExpression(cursor, 0)
+		if !ok {
+			p.syntaxError(cursor, "Expected expression to order by", "expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		item := OrderByItem{Exp: exp}
+		if p.expectToken(cursor, tokenFromKeyword(descKeyword)) {
+			item.Desc = true
+			cursor++
+		} else if p.expectToken(cursor, tokenFromKeyword(ascKeyword)) {
+			cursor++
+		}
+		items = append(items, &item)
+
+		if !p.expectToken(cursor, tokenFromSymbol(commaSymbol)) {
+			break
+		}
+		cursor++
+	}
+
+	return &items, cursor, true
+}
+
+// BETWEEN bound AND bound | bound
+func (p *parser) parseWindowFrame(initialCursor uint) (*WindowFrame, uint, bool) {
+	cursor := initialCursor
+
+	if !p.expectToken(cursor, tokenFromKeyword(betweenKeyword)) {
+		// A lone bound is the start of a frame ending at the current row
+		start, newCursor, ok := p.parseFrameBound(cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		return &WindowFrame{Start: *start, End: FrameBound{Kind: CurrentRowBound}}, newCursor, true
+	}
+	cursor++
+
+	start, newCursor, ok := p.parseFrameBound(cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !p.expectToken(cursor, tokenFromKeyword(andKeyword)) {
+		p.syntaxError(cursor, "Expected AND between frame bounds", "AND")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	end, newCursor, ok := p.parseFrameBound(cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	return &WindowFrame{Start: *start, End: *end}, cursor, true
+}
+
+// UNBOUNDED PRECEDING | n PRECEDING | CURRENT ROW | n FOLLOWING | UNBOUNDED FOLLOWING
+func (p *parser) parseFrameBound(initialCursor uint) (*FrameBound, uint, bool) {
+	cursor := initialCursor
+
+	if p.expectToken(cursor, tokenFromKeyword(currentKeyword)) {
+		cursor++
+
+		if !p.expectToken(cursor, tokenFromKeyword(rowKeyword)) {
+			p.syntaxError(cursor, "Expected ROW after CURRENT", "ROW")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		return &FrameBound{Kind: CurrentRowBound}, cursor, true
+	}
+
+	var offset *Token
+	if p.expectToken(cursor, tokenFromKeyword(unboundedKeyword)) {
+		cursor++
+	} else if t, newCursor, ok := p.parseToken(cursor, NumericKind); ok {
+		offset = t
+		cursor = newCursor
+	} else {
+		p.syntaxError(cursor, "Expected frame bound", "UNBOUNDED", "CURRENT", "offset")
+		return nil, initialCursor, false
+	}
+
+	bound := FrameBound{Offset: offset}
+	switch {
+	case p.expectToken(cursor, tokenFromKeyword(precedingKeyword)):
+		bound.Kind = PrecedingBound
+		if offset == nil {
+			bound.Kind = UnboundedPrecedingBound
+		}
+	case p.expectToken(cursor, tokenFromKeyword(followingKeyword)):
+		bound.Kind = FollowingBound
+		if offset == nil {
+			bound.Kind = UnboundedFollowingBound
+		}
+	default:
+		p.syntaxError(cursor, "Expected PRECEDING or FOLLOWING", "PRECEDING", "FOLLOWING")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &bound, cursor, true
 }
@@ -0,0 +1,147 @@
+package gosql
+
+import (
+	"io"
+	"strings"
+)
+
+// streamChunkSize is the least amount of source a Parser reads at once.
+const streamChunkSize = 64 << 10
+
+// Parser parses the statements of a source read from an io.Reader one at
+// a time. Only the statement being parsed is held in memory, along with
+// whatever was read past its end, so arbitrarily large scripts can be
+// replayed.
+type Parser struct {
+	r io.Reader
+	// pending is the source read but not parsed yet, which starts at loc
+	pending string
+	loc     Location
+	eof     bool
+	// err is a lex or read error that parsing can't resume after
+	err error
+}
+
+// NewParser returns a Parser reading the source from r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: r}
+}
+
+// Next parses and returns the next statement of the source, or io.EOF
+// once every statement was returned. Syntax errors are returned as
+// ParseErrors, after which Next resumes with the following statement the
+// way Parse does. Empty statements are skipped.
+func (p *Parser) Next() (*Statement, error) {
+	for p.err == nil {
+		tokens, cur, complete, lexErr := p.lexStatement()
+		if lexErr != nil {
+			p.err = ParseErrors{lexErr}
+			break
+		}
+
+		if !complete {
+			if err := p.fill(); err != nil {
+				p.err = err
+			}
+			continue
+		}
+
+		p.pending = p.pending[cur.pointer:]
+		p.loc = cur.loc
+		if len(tokens) == 0 {
+			return nil, io.EOF
+		}
+
+		ast, err := parseTokens(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		return ast.Statements[0], nil
+	}
+
+	return nil, p.err
+}
+
+// lexStatement lexes the pending source up to and including the semicolon
+// ending its first statement, returning the cursor past it. The statement
+// is not complete when the source read so far ends before that semicolon,
+// in which case it is lexed again once more was read.
+func (p *Parser) lexStatement() ([]*Token, cursor, bool, *ParseError) {
+	var tokens []*Token
+	cur := cursor{loc: p.loc}
+	semicolonToken := tokenFromSymbol(semicolonSymbol)
+
+	for cur.pointer < uint(len(p.pending)) {
+		token, newCursor, ok := lexToken(p.pending, cur)
+		if !ok {
+			if !p.eof && mayContinue(p.pending[cur.pointer:]) {
+				return nil, cur, false, nil
+			}
+
+			var previous *Token
+			if len(tokens) > 0 {
+				previous = tokens[len(tokens)-1]
+			}
+			return nil, cur, false, lexError(p.pending, cur, previous)
+		}
+		cur = newCursor
+
+		if token == nil || token.Kind == CommentKind {
+			continue
+		}
+
+		if token.equals(&semicolonToken) {
+			if len(tokens) == 0 {
+				// Skip empty statements
+				p.pending = p.pending[cur.pointer:]
+				p.loc = cur.loc
+				cur.pointer = 0
+				continue
+			}
+
+			return append(tokens, token), cur, true, nil
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, cur, p.eof, nil
+}
+
+// mayContinue reports whether source that failed to lex could still
+// become valid once more of the source is read: a string, quoted
+// identifier or block comment may be missing its end, and any other token
+// running up to the end of what was read may have been cut short.
+func mayContinue(rest string) bool {
+	for _, prefix := range []string{"'", "E'", "e'", "$", `"`, "/*"} {
+		if strings.HasPrefix(rest, prefix) {
+			return true
+		}
+	}
+
+	return !strings.ContainsAny(rest, " \t\r\n;,()")
+}
+
+// fill reads more of the source, at least as much again as is pending so
+// that a statement spanning many reads is only lexed a few times.
+func (p *Parser) fill() error {
+	size := streamChunkSize
+	if len(p.pending) > size {
+		size = len(p.pending)
+	}
+
+	buf := make([]byte, size)
+	for {
+		n, err := p.r.Read(buf)
+		p.pending += string(buf[:n])
+		if err == io.EOF {
+			p.eof = true
+			return nil
+		}
+
+		if err != nil || n > 0 {
+			return err
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package gosql
+
+// transactionKinds maps the keywords of transaction control statements
+// to the kind of statement they make.
+var transactionKinds = []struct {
+	keyword keyword
+	kind    AstKind
+}{
+	{beginKeyword, BeginKind},
+	{commitKeyword, CommitKind},
+	{rollbackKeyword, RollbackKind},
+}
+
+// BEGIN, COMMIT, ROLLBACK or one of the savepoint statements
+func (p *parser) parseTransactionStatement(initialCursor uint) (*Statement, uint, bool) {
+	cursor := initialCursor
+	switch {
+	case p.expectToken(cursor, tokenFromKeyword(savepointKeyword)):
+		return p.parseSavepointStatement(cursor+1, SavepointKind)
+	case p.expectToken(cursor, tokenFromKeyword(releaseKeyword)):
+		return p.parseSavepointStatement(cursor+1, ReleaseSavepointKind)
+	case p.expectToken(cursor, tokenFromKeyword(rollbackKeyword)) &&
+		p.expectToken(cursor+1, tokenFromKeyword(toKeyword)):
+		return p.parseSavepointStatement(cursor+2, RollbackToSavepointKind)
+	}
+
+	for _, tk := range transactionKinds {
+		if p.expectToken(cursor, tokenFromKeyword(tk.keyword)) {
+			return &Statement{Kind: tk.kind}, cursor + 1, true
+		}
+	}
+
+	return nil, initialCursor, false
+}
+
+// [SAVEPOINT] name, following SAVEPOINT, RELEASE or ROLLBACK TO. The
+// SAVEPOINT keyword is only optional after the latter two.
+func (p *parser) parseSavepointStatement(initialCursor uint, kind AstKind) (*Statement, uint, bool) {
+	cursor := initialCursor
+	if kind != SavepointKind && p.expectToken(cursor, tokenFromKeyword(savepointKeyword)) {
+		cursor++
+	}
+
+	name, newCursor, ok := p.parseIdentifier(cursor)
+	if !ok {
+		p.syntaxError(cursor, "Expected savepoint name", "identifier")
+		return nil, initialCursor, false
+	}
+
+	return &Statement{
+		Kind:               kind,
+		SavepointStatement: &SavepointStatement{Name: *name},
+	}, newCursor, true
+}
@@ -3,79 +3,317 @@ package gosql
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
-func tokenFromKeyword(k keyword) token {
-	return token{kind: keywordKind, value: string(k)}
+func tokenFromKeyword(k keyword) Token {
+	return Token{Kind: KeywordKind, Value: string(k)}
 }
 
-func tokenFromSymbol(s symbol) token {
-	return token{kind: symbolKind, value: string(s)}
+func tokenFromSymbol(s symbol) Token {
+	return Token{Kind: SymbolKind, Value: string(s)}
 }
 
-func expectToken(tokens []*token, cursor uint, t token) bool {
-	if cursor >= uint(len(tokens)) {
+// predicateBindingPower is shared by the IN, BETWEEN, LIKE and ILIKE
+// predicates and the NOT that can negate them.
+const predicateBindingPower = 4
+
+// bindingPower returns how tightly a binary operator binds its operands,
+// or zero when the token is not a binary operator.
+func (t *Token) bindingPower() uint {
+	switch t.Kind {
+	case KeywordKind:
+		switch keyword(t.Value) {
+		case orKeyword:
+			return 1
+		case andKeyword:
+			return 2
+		case notKeyword, inKeyword, betweenKeyword, likeKeyword, ilikeKeyword:
+			return predicateBindingPower
+		}
+	case SymbolKind:
+		switch symbol(t.Value) {
+		case eqSymbol, neqSymbol, neqSymbol2, ltSymbol, lteSymbol, gtSymbol, gteSymbol:
+			return 3
+		case arrowSymbol, doubleArrowSymbol, tildeSymbol:
+			return 5
+		case castSymbol:
+			return 10
+		}
+	}
+
+	return 0
+}
+
+// ParseError describes a syntax error at a location in the source.
+type ParseError struct {
+	Loc Location
+	// Token is the offending token, or nil when the source couldn't be
+	// lexed or ended early
+	Token *Token
+	// Expected lists the tokens or constructs that would have been valid
+	// in place of Token, when known
+	Expected []string
+	Msg      string
+}
+
+func (e *ParseError) Error() string {
+	got := "end of input"
+	if e.Token != nil {
+		got = e.Token.Value
+	}
+
+	msg := fmt.Sprintf("[%d,%d]: %s, got: %s", e.Loc.Line, e.Loc.Col, e.Msg, got)
+	if len(e.Expected) > 0 {
+		var expected []string
+		for _, exp := range e.Expected {
+			expected = append(expected, strconv.Quote(exp))
+		}
+		msg += " (expected " + strings.Join(expected, " or ") + ")"
+	}
+
+	return msg
+}
+
+// parser holds the tokens of a source along with the syntax error found
+// furthest into them, which is the most specific one when parsing had to
+// backtrack.
+type parser struct {
+	tokens    []*Token
+	err       *ParseError
+	errCursor uint
+	// implicitSemicolon terminates sources that don't end in a semicolon
+	implicitSemicolon *Token
+}
+
+func (p *parser) expectToken(cursor uint, t Token) bool {
+	if cursor >= uint(len(p.tokens)) {
 		return false
 	}
 
-	return t.equals(tokens[cursor])
+	return t.equals(p.tokens[cursor])
 }
 
-func helpMessage(tokens []*token, cursor uint, msg string) {
-	var c *token
-	if cursor < uint(len(tokens)) {
-		c = tokens[cursor]
-	} else {
-		c = tokens[cursor-1]
+// syntaxError records an error at the token at cursor, unless an error
+// was already recorded there or further on. Errors are reported from the
+// innermost construct outwards, so the first one at a token is the most
+// specific.
+func (p *parser) syntaxError(cursor uint, msg string, expected ...string) {
+	if p.err != nil && p.errCursor >= cursor {
+		return
 	}
 
-	fmt.Printf("[%d,%d]: %s, got: %s\n", c.loc.line, c.loc.col, msg, c.value)
+	err := ParseError{Msg: msg, Expected: expected}
+	if cursor < uint(len(p.tokens)) && p.tokens[cursor] != p.implicitSemicolon {
+		err.Token = p.tokens[cursor]
+		err.Loc = err.Token.Loc
+	} else if len(p.tokens) > 0 {
+		// Past the end, point at the last token of the source
+		last := p.tokens[len(p.tokens)-1]
+		if last == p.implicitSemicolon && len(p.tokens) > 1 {
+			last = p.tokens[len(p.tokens)-2]
+		}
+		err.Loc = last.Loc
+	}
+
+	p.err = &err
+	p.errCursor = cursor
+}
+
+// ParseErrors lists every syntax error found in a source, in order.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	switch len(e) {
+	case 0:
+		return "no errors"
+	case 1:
+		return e[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more errors)", e[0], len(e)-1)
+}
+
+// Is lets errors.Is match any of the individual errors. Unlike an Unwrap
+// method returning them all, it works on Go versions before 1.20.
+func (e ParseErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
 }
 
+// As lets errors.As find the first individual error matching target.
+func (e ParseErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parse parses a source of semicolon separated statements. When a
+// statement isn't valid, parsing resumes after the next semicolon so that
+// a single pass reports every invalid statement. The errors are returned
+// as ParseErrors along with an Ast of the statements that were valid.
 func Parse(source string) (*Ast, error) {
 	tokens, err := lex(source)
 	if err != nil {
-		return nil, err
+		return nil, ParseErrors{err.(*ParseError)}
 	}
 
+	return parseTokens(tokens)
+}
+
+// ParseWithComments parses a source the way Parse does and attaches its
+// comments to the statements so that formatting them keeps the comments.
+// A comment following a statement on the line it ends trails it, and any
+// other comment leads the statement it precedes or is inside of. Comments
+// are dropped when the source isn't valid.
+func ParseWithComments(source string) (*Ast, error) {
+	tokens, err := Lex(source, true)
+	if err != nil {
+		return nil, ParseErrors{err.(*ParseError)}
+	}
+
+	var code, comments []*Token
+	for _, token := range tokens {
+		if token.Kind == CommentKind {
+			comments = append(comments, token)
+		} else {
+			code = append(code, token)
+		}
+	}
+
+	ast, err := parseTokens(code)
+	if err != nil {
+		return ast, err
+	}
+
+	attachComments(source, ast, code, comments)
+	return ast, nil
+}
+
+// attachComments attaches the comments of a valid source to the statements
+// parsed from the rest of its tokens.
+func attachComments(source string, ast *Ast, code, comments []*Token) {
+	// A statement spans from its first token through the semicolons ending
+	// it, which is how parseTokens splits a valid source
+	semicolon := tokenFromSymbol(semicolonSymbol)
+	var starts, ends []*Token
+	for i, token := range code {
+		if i == 0 || (code[i-1].equals(&semicolon) && !token.equals(&semicolon)) {
+			starts = append(starts, token)
+		}
+
+		if i == len(code)-1 || (token.equals(&semicolon) && !code[i+1].equals(&semicolon)) {
+			ends = append(ends, token)
+		}
+	}
+
+	statements := ast.Statements
+	next := 0
+	for _, comment := range comments {
+		// next is the first statement that doesn't end before the comment
+		for next < len(statements) && ends[next].Loc.Offset < comment.Loc.Offset {
+			next++
+		}
+
+		if next > 0 && (next == len(statements) || comment.Loc.Offset < starts[next].Loc.Offset) &&
+			comment.Loc.Line == endLine(source, ends[next-1]) {
+			statements[next-1].TrailingComments = append(statements[next-1].TrailingComments, comment)
+			continue
+		}
+
+		if next < len(statements) {
+			statements[next].LeadingComments = append(statements[next].LeadingComments, comment)
+		} else {
+			ast.TrailingComments = append(ast.TrailingComments, comment)
+		}
+	}
+}
+
+// endLine returns the line a token of the source ends on.
+func endLine(source string, token *Token) uint {
+	_, cur, _ := lexToken(source, cursor{pointer: token.Loc.Offset, loc: token.Loc})
+	return cur.loc.Line
+}
+
+// parseTokens parses the statements of a lexed source the way Parse does.
+func parseTokens(tokens []*Token) (*Ast, error) {
+	p := parser{tokens: tokens}
+
 	semicolonToken := tokenFromSymbol(semicolonSymbol)
 	if len(tokens) > 0 && !tokens[len(tokens)-1].equals(&semicolonToken) {
-		tokens = append(tokens, &semicolonToken)
+		p.implicitSemicolon = &semicolonToken
+		p.tokens = append(p.tokens, p.implicitSemicolon)
 	}
 
 	a := Ast{}
+	var errs ParseErrors
 	cursor := uint(0)
-	for cursor < uint(len(tokens)) {
-		stmt, newCursor, ok := parseStatement(tokens, cursor, tokenFromSymbol(semicolonSymbol))
+	for cursor < uint(len(p.tokens)) {
+		p.err = nil
+
+		stmt, newCursor, ok := p.parseStatement(cursor, tokenFromSymbol(semicolonSymbol))
 		if !ok {
-			helpMessage(tokens, cursor, "Expected statement")
-			return nil, errors.New("failed to parse, expected statement")
+			p.syntaxError(cursor, "Expected statement", "SELECT", "INSERT", "CREATE", "BEGIN", "COMMIT", "ROLLBACK", "SAVEPOINT", "RELEASE")
+			errs = append(errs, p.err)
+			cursor = p.synchronize(p.errCursor)
+			continue
 		}
-		cursor = newCursor
-
-		a.Statements = append(a.Statements, stmt)
 
 		atLeastOneSemicolon := false
-		for expectToken(tokens, cursor, tokenFromSymbol(semicolonSymbol)) {
-			cursor++
+		for p.expectToken(newCursor, tokenFromSymbol(semicolonSymbol)) {
+			newCursor++
 			atLeastOneSemicolon = true
 		}
 
 		if !atLeastOneSemicolon {
-			helpMessage(tokens, cursor, "Expected semicolon delimiter between statements")
-			return nil, errors.New("missing semicolon between statements")
+			p.err = nil
+			p.syntaxError(newCursor, "Expected semicolon delimiter between statements", ";")
+			errs = append(errs, p.err)
+			cursor = p.synchronize(newCursor)
+			continue
 		}
+
+		a.Statements = append(a.Statements, stmt)
+		cursor = newCursor
+	}
+
+	if len(errs) > 0 {
+		return &a, errs
 	}
 
 	return &a, nil
 }
 
-func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Statement, uint, bool) {
+// synchronize returns the cursor of the statement following the one an
+// error was found at, after the next semicolon at or past cursor.
+func (p *parser) synchronize(cursor uint) uint {
+	for cursor < uint(len(p.tokens)) && !p.expectToken(cursor, tokenFromSymbol(semicolonSymbol)) {
+		cursor++
+	}
+
+	for p.expectToken(cursor, tokenFromSymbol(semicolonSymbol)) {
+		cursor++
+	}
+
+	return cursor
+}
+
+func (p *parser) parseStatement(initialCursor uint, delimiter Token) (*Statement, uint, bool) {
 	cursor := initialCursor
 
 	// Look for SELECT statement
 	semicolonToken := tokenFromSymbol(semicolonSymbol)
-	slct, newCursor, ok := parseSelectStatement(tokens, cursor, semicolonToken)
+	slct, newCursor, ok := p.parseSelectStatement(cursor, semicolonToken)
 	if ok {
 		return &Statement{
 			Kind:            SelectKind,
@@ -84,7 +322,7 @@ func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Stat
 	}
 
 	// Look for INSERT statement
-	inst, newCursor, ok := parseInsertStatement(tokens, cursor, semicolonToken)
+	inst, newCursor, ok := p.parseInsertStatement(cursor, semicolonToken)
 	if ok {
 		return &Statement{
 			Kind:            InsertKind,
@@ -93,7 +331,7 @@ func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Stat
 	}
 
 	// Look for CREATE statment
-	crtTbl, newCursor, ok := parseCreateTableStatement(tokens, cursor, semicolonToken)
+	crtTbl, newCursor, ok := p.parseCreateTableStatement(cursor, semicolonToken)
 	if ok {
 		return &Statement{
 			Kind:                 CreateTableKind,
@@ -101,50 +339,78 @@ func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Stat
 		}, newCursor, true
 	}
 
+	crtIdx, newCursor, ok := p.parseCreateIndexStatement(cursor)
+	if ok {
+		return &Statement{
+			Kind:                 CreateIndexKind,
+			CreateIndexStatement: crtIdx,
+		}, newCursor, true
+	}
+
+	// Look for BEGIN, COMMIT or ROLLBACK
+	if stmt, newCursor, ok := p.parseTransactionStatement(cursor); ok {
+		return stmt, newCursor, true
+	}
+
 	return nil, initialCursor, false
 }
 
-func parseToken(tokens []*token, initialCursor uint, kind tokenKind) (*token, uint, bool) {
+func (p *parser) parseToken(initialCursor uint, kind TokenKind) (*Token, uint, bool) {
 	cursor := initialCursor
 
-	if cursor >= uint(len(tokens)) {
+	if cursor >= uint(len(p.tokens)) {
 		return nil, initialCursor, false
 	}
 
-	current := tokens[cursor]
-	if current.kind == kind {
+	current := p.tokens[cursor]
+	if current.Kind == kind {
 		return current, cursor + 1, true
 	}
 
 	return nil, initialCursor, false
 }
 
-func parseExpressions(tokens []*token, initialCursor uint, delimiter token) (*[]*expression, uint, bool) {
+// parseIdentifier parses an identifier, which may also be spelled like a
+// non-reserved keyword.
+func (p *parser) parseIdentifier(initialCursor uint) (*Token, uint, bool) {
+	if ident, newCursor, ok := p.parseToken(initialCursor, IdentifierKind); ok {
+		return ident, newCursor, true
+	}
+
+	kw, newCursor, ok := p.parseToken(initialCursor, KeywordKind)
+	if !ok || !nonReservedKeywords[keyword(kw.Value)] {
+		return nil, initialCursor, false
+	}
+
+	return &Token{Value: kw.Value, Kind: IdentifierKind, Loc: kw.Loc}, newCursor, true
+}
+
+func (p *parser) parseExpressions(initialCursor uint, delimiter Token) (*[]*Expression, uint, bool) {
 	cursor := initialCursor
 
-	exps := []*expression{}
+	exps := []*Expression{}
 	for {
-		if cursor >= uint(len(tokens)) {
+		if cursor >= uint(len(p.tokens)) {
 			return nil, initialCursor, false
 		}
 
-		current := tokens[cursor]
+		current := p.tokens[cursor]
 		if delimiter.equals(current) {
 			break
 		}
 
 		if len(exps) > 0 {
-			if !expectToken(tokens, cursor, tokenFromSymbol(commaSymbol)) {
-				helpMessage(tokens, cursor, "Expected comma")
+			if !p.expectToken(cursor, tokenFromSymbol(commaSymbol)) {
+				p.syntaxError(cursor, "Expected comma", ",", delimiter.Value)
 				return nil, initialCursor, false
 			}
 
 			cursor++
 		}
 
-		exp, newCursor, ok := parseExpression(tokens, cursor, tokenFromSymbol(commaSymbol))
+		exp, newCursor, ok := p.parseExpression(cursor, 0)
 		if !ok {
-			helpMessage(tokens, cursor, "Expected expression")
+			p.syntaxError(cursor, "Expected expression", "expression")
 			return nil, initialCursor, false
 		}
 
@@ -155,19 +421,409 @@ func parseExpressions(tokens []*token, initialCursor uint, delimiter token) (*[]
 	return &exps, cursor, true
 }
 
-func parseExpression(tokens []*token, initialCursor uint, _ token) (*expression, uint, bool) {
+// expression [, ...]
+func (p *parser) parseExpressionList(initialCursor uint) (*[]*Expression, uint, bool) {
+	cursor := initialCursor
+
+	exps := []*Expression{}
+	for {
+		exp, newCursor, ok := p.parseExpression(cursor, 0)
+		if !ok {
+			p.syntaxError(cursor, "Expected expression", "expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+		exps = append(exps, exp)
+
+		if !p.expectToken(cursor, tokenFromSymbol(commaSymbol)) {
+			break
+		}
+		cursor++
+	}
+
+	return &exps, cursor, true
+}
+
+// parseExpression parses a literal, function call, cast, CASE or parenthesized
+// expression followed by any number of binary operators, predicates and
+// :: casts whose binding power is greater than minBp.
+func (p *parser) parseExpression(initialCursor uint, minBp uint) (*Expression, uint, bool) {
 	cursor := initialCursor
 
-	kinds := []tokenKind{identifierKind, numericKind, stringKind}
+	var exp *Expression
+	if p.expectToken(cursor, tokenFromSymbol(leftParenSymbol)) {
+		cursor++
+
+		inner, newCursor, ok := p.parseExpression(cursor, 0)
+		if !ok {
+			p.syntaxError(cursor, "Expected expression after opening paren", "expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		if !p.expectToken(cursor, tokenFromSymbol(rightParenSymbol)) {
+			p.syntaxError(cursor, "Expected closing paren", ")")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		exp = inner
+	} else if caseExp, newCursor, ok := p.parseCaseExpression(cursor); ok {
+		exp = caseExp
+		cursor = newCursor
+	} else if cast, newCursor, ok := p.parseCastExpression(cursor); ok {
+		exp = cast
+		cursor = newCursor
+	} else if call, newCursor, ok := p.parseCallExpression(cursor); ok {
+		exp = call
+		cursor = newCursor
+	} else {
+		lit, newCursor, ok := p.parseLiteralExpression(cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		exp = lit
+		cursor = newCursor
+	}
+
+	for cursor < uint(len(p.tokens)) {
+		op := p.tokens[cursor]
+		bp := op.bindingPower()
+		if bp == 0 || bp <= minBp {
+			break
+		}
+		cursor++
+
+		if op.Value == string(castSymbol) {
+			datatype, newCursor, ok := p.parseToken(cursor, KeywordKind)
+			if !ok {
+				p.syntaxError(cursor, "Expected type after ::", "type")
+				return nil, initialCursor, false
+			}
+			cursor = newCursor
+
+			exp = &Expression{
+				Cast: &CastExpression{Exp: *exp, Datatype: *datatype},
+				Kind: CastKind,
+			}
+			continue
+		}
+
+		if op.Kind == KeywordKind && bp == predicateBindingPower {
+			predicate, newCursor, ok := p.parsePredicate(cursor, exp, op)
+			if !ok {
+				return nil, initialCursor, false
+			}
+			cursor = newCursor
+
+			exp = predicate
+			continue
+		}
+
+		b, newCursor, ok := p.parseExpression(cursor, bp)
+		if !ok {
+			p.syntaxError(cursor, "Expected right operand", "expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		exp = &Expression{
+			Binary: &BinaryExpression{A: *exp, B: *b, Op: *op},
+			Kind:   BinaryKind,
+		}
+	}
+
+	return exp, cursor, true
+}
+
+func (p *parser) parseLiteralExpression(initialCursor uint) (*Expression, uint, bool) {
+	if ident, newCursor, ok := p.parseIdentifier(initialCursor); ok {
+		return &Expression{
+			Literal: ident,
+			Kind:    LiteralKind,
+		}, newCursor, true
+	}
+
+	kinds := []TokenKind{NumericKind, StringKind, BoolKind, NullKind, ParameterKind}
 	for _, kind := range kinds {
-		t, newCursor, ok := parseToken(tokens, cursor, kind)
+		t, newCursor, ok := p.parseToken(initialCursor, kind)
 		if ok {
-			return &expression{
-				literal: t,
-				kind:    literalKind,
+			return &Expression{
+				Literal: t,
+				Kind:    LiteralKind,
 			}, newCursor, true
 		}
 	}
 
 	return nil, initialCursor, false
 }
+
+// ident ( [expression [, ...]] )
+func (p *parser) parseCallExpression(initialCursor uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	name, newCursor, ok := p.parseIdentifier(cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !p.expectToken(cursor, tokenFromSymbol(leftParenSymbol)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if p.expectToken(cursor, tokenFromSymbol(asteriskSymbol)) {
+		cursor++
+
+		if !p.expectToken(cursor, tokenFromSymbol(rightParenSymbol)) {
+			p.syntaxError(cursor, "Expected ) after *", ")")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		return &Expression{
+			Call: &CallExpression{Name: *name, Args: &[]*Expression{}, Asterisk: true},
+			Kind: CallKind,
+		}, cursor, true
+	}
+
+	args, newCursor, ok := p.parseExpressions(cursor, tokenFromSymbol(rightParenSymbol))
+	if !ok {
+		p.syntaxError(cursor, "Expected function arguments", "expression")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !p.expectToken(cursor, tokenFromSymbol(rightParenSymbol)) {
+		p.syntaxError(cursor, "Expected )", ")")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &Expression{
+		Call: &CallExpression{Name: *name, Args: args},
+		Kind: CallKind,
+	}, cursor, true
+}
+
+// CAST ( expression AS type )
+func (p *parser) parseCastExpression(initialCursor uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	if !p.expectToken(cursor, tokenFromKeyword(castKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !p.expectToken(cursor, tokenFromSymbol(leftParenSymbol)) {
+		p.syntaxError(cursor, "Expected ( after CAST", "(")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	exp, newCursor, ok := p.parseExpression(cursor, 0)
+	if !ok {
+		p.syntaxError(cursor, "Expected expression to cast", "expression")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !p.expectToken(cursor, tokenFromKeyword(asKeyword)) {
+		p.syntaxError(cursor, "Expected AS", "AS")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	datatype, newCursor, ok := p.parseToken(cursor, KeywordKind)
+	if !ok {
+		p.syntaxError(cursor, "Expected type", "type")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !p.expectToken(cursor, tokenFromSymbol(rightParenSymbol)) {
+		p.syntaxError(cursor, "Expected )", ")")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &Expression{
+		Cast: &CastExpression{Exp: *exp, Datatype: *datatype},
+		Kind: CastKind,
+	}, cursor, true
+}
+
+// parsePredicate parses the remainder of a predicate whose left operand
+// exp and keyword op have already been consumed:
+//
+//	[NOT] IN ( expression [, ...] )
+//	[NOT] BETWEEN expression AND expression
+//	[NOT] { LIKE | ILIKE } expression [ESCAPE expression]
+func (p *parser) parsePredicate(initialCursor uint, exp *Expression, op *Token) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	not := op.Value == string(notKeyword)
+	if not {
+		if cursor >= uint(len(p.tokens)) || p.tokens[cursor].Kind != KeywordKind ||
+			p.tokens[cursor].Value == string(notKeyword) || p.tokens[cursor].bindingPower() != predicateBindingPower {
+			p.syntaxError(cursor, "Expected IN, BETWEEN, LIKE or ILIKE after NOT", "IN", "BETWEEN", "LIKE", "ILIKE")
+			return nil, initialCursor, false
+		}
+
+		op = p.tokens[cursor]
+		cursor++
+	}
+
+	switch keyword(op.Value) {
+	case inKeyword:
+		if !p.expectToken(cursor, tokenFromSymbol(leftParenSymbol)) {
+			p.syntaxError(cursor, "Expected opening paren after IN", "(")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		list, newCursor, ok := p.parseExpressionList(cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		if !p.expectToken(cursor, tokenFromSymbol(rightParenSymbol)) {
+			p.syntaxError(cursor, "Expected closing paren", ")")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		return &Expression{
+			In:   &InExpression{Exp: *exp, List: list, Not: not},
+			Kind: InKind,
+		}, cursor, true
+	case betweenKeyword:
+		low, newCursor, ok := p.parseExpression(cursor, predicateBindingPower)
+		if !ok {
+			p.syntaxError(cursor, "Expected lower bound", "expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		if !p.expectToken(cursor, tokenFromKeyword(andKeyword)) {
+			p.syntaxError(cursor, "Expected AND", "AND")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		high, newCursor, ok := p.parseExpression(cursor, predicateBindingPower)
+		if !ok {
+			p.syntaxError(cursor, "Expected upper bound", "expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		return &Expression{
+			Between: &BetweenExpression{Exp: *exp, Low: *low, High: *high, Not: not},
+			Kind:    BetweenKind,
+		}, cursor, true
+	}
+
+	pattern, newCursor, ok := p.parseExpression(cursor, predicateBindingPower)
+	if !ok {
+		p.syntaxError(cursor, "Expected pattern", "expression")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	var escape *Expression
+	if p.expectToken(cursor, tokenFromKeyword(escapeKeyword)) {
+		cursor++
+
+		escape, newCursor, ok = p.parseExpression(cursor, predicateBindingPower)
+		if !ok {
+			p.syntaxError(cursor, "Expected escape character", "expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+	}
+
+	return &Expression{
+		Like: &LikeExpression{Exp: *exp, Pattern: *pattern, Escape: escape, Op: *op, Not: not},
+		Kind: LikeKind,
+	}, cursor, true
+}
+
+// CASE [expression] WHEN expression THEN expression [...] [ELSE expression] END
+func (p *parser) parseCaseExpression(initialCursor uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	if !p.expectToken(cursor, tokenFromKeyword(caseKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	caseExp := CaseExpression{}
+	if !p.expectToken(cursor, tokenFromKeyword(whenKeyword)) {
+		operand, newCursor, ok := p.parseExpression(cursor, 0)
+		if !ok {
+			p.syntaxError(cursor, "Expected WHEN or expression after CASE", "WHEN", "expression")
+			return nil, initialCursor, false
+		}
+
+		caseExp.Operand = operand
+		cursor = newCursor
+	}
+
+	whens := []*WhenClause{}
+	for p.expectToken(cursor, tokenFromKeyword(whenKeyword)) {
+		cursor++
+
+		when, newCursor, ok := p.parseExpression(cursor, 0)
+		if !ok {
+			p.syntaxError(cursor, "Expected expression after WHEN", "expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		if !p.expectToken(cursor, tokenFromKeyword(thenKeyword)) {
+			p.syntaxError(cursor, "Expected THEN", "THEN")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		then, newCursor, ok := p.parseExpression(cursor, 0)
+		if !ok {
+			p.syntaxError(cursor, "Expected expression after THEN", "expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		whens = append(whens, &WhenClause{When: when, Then: then})
+	}
+
+	if len(whens) == 0 {
+		p.syntaxError(cursor, "Expected WHEN", "WHEN")
+		return nil, initialCursor, false
+	}
+	caseExp.Whens = &whens
+
+	if p.expectToken(cursor, tokenFromKeyword(elseKeyword)) {
+		cursor++
+
+		elseExp, newCursor, ok := p.parseExpression(cursor, 0)
+		if !ok {
+			p.syntaxError(cursor, "Expected expression after ELSE", "expression")
+			return nil, initialCursor, false
+		}
+
+		caseExp.Else = elseExp
+		cursor = newCursor
+	}
+
+	if !p.expectToken(cursor, tokenFromKeyword(endKeyword)) {
+		p.syntaxError(cursor, "Expected END", "END")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &Expression{Case: &caseExp, Kind: CaseKind}, cursor, true
+}
@@ -1,7 +1,11 @@
 package gosql
 
 import (
+	"errors"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -18,27 +22,27 @@ func TestParse(t *testing.T) {
 					{
 						Kind: InsertKind,
 						InsertStatement: &InsertStatement{
-							table: token{
-								loc:   location{col: 12, line: 0},
-								kind:  identifierKind,
-								value: "users",
+							Table: Token{
+								Loc:   Location{Col: 12, Line: 0, Offset: 12},
+								Kind:  IdentifierKind,
+								Value: "users",
 							},
-							values: &[]*expression{
+							Values: &[]*Expression{
 								{
-									literal: &token{
-										loc:   location{col: 26, line: 0},
-										kind:  numericKind,
-										value: "105",
+									Literal: &Token{
+										Loc:   Location{Col: 26, Line: 0, Offset: 26},
+										Kind:  NumericKind,
+										Value: "105",
 									},
-									kind: literalKind,
+									Kind: LiteralKind,
 								},
 								{
-									literal: &token{
-										loc:   location{col: 32, line: 0},
-										kind:  numericKind,
-										value: "233",
+									Literal: &Token{
+										Loc:   Location{Col: 31, Line: 0, Offset: 31},
+										Kind:  NumericKind,
+										Value: "233",
 									},
-									kind: literalKind,
+									Kind: LiteralKind,
 								},
 							},
 						},
@@ -53,34 +57,34 @@ func TestParse(t *testing.T) {
 					{
 						Kind: CreateTableKind,
 						CreateTableStatement: &CreateTableStatement{
-							name: token{
-								loc:   location{col: 13, line: 0},
-								kind:  identifierKind,
-								value: "users",
+							Name: Token{
+								Loc:   Location{Col: 13, Line: 0, Offset: 13},
+								Kind:  IdentifierKind,
+								Value: "users",
 							},
-							cols: &[]*columnDefinition{
+							Cols: &[]*ColumnDefinition{
 								{
-									name: token{
-										loc:   location{col: 20, line: 0},
-										kind:  identifierKind,
-										value: "id",
+									Name: Token{
+										Loc:   Location{Col: 20, Line: 0, Offset: 20},
+										Kind:  IdentifierKind,
+										Value: "id",
 									},
-									datatype: token{
-										loc:   location{col: 23, line: 0},
-										kind:  keywordKind,
-										value: "int",
+									Datatype: Token{
+										Loc:   Location{Col: 23, Line: 0, Offset: 23},
+										Kind:  KeywordKind,
+										Value: "int",
 									},
 								},
 								{
-									name: token{
-										loc:   location{col: 28, line: 0},
-										kind:  identifierKind,
-										value: "name",
+									Name: Token{
+										Loc:   Location{Col: 28, Line: 0, Offset: 28},
+										Kind:  IdentifierKind,
+										Value: "name",
 									},
-									datatype: token{
-										loc:   location{col: 33, line: 0},
-										kind:  keywordKind,
-										value: "text",
+									Datatype: Token{
+										Loc:   Location{Col: 33, Line: 0, Offset: 33},
+										Kind:  KeywordKind,
+										Value: "text",
 									},
 								},
 							},
@@ -89,6 +93,67 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			source: "create table t (json text)",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: CreateTableKind,
+						CreateTableStatement: &CreateTableStatement{
+							Name: Token{
+								Loc:   Location{Col: 13, Line: 0, Offset: 13},
+								Kind:  IdentifierKind,
+								Value: "t",
+							},
+							Cols: &[]*ColumnDefinition{
+								{
+									Name: Token{
+										Loc:   Location{Col: 16, Line: 0, Offset: 16},
+										Kind:  IdentifierKind,
+										Value: "json",
+									},
+									Datatype: Token{
+										Loc:   Location{Col: 21, Line: 0, Offset: 21},
+										Kind:  KeywordKind,
+										Value: "text",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "create index i on t (name)",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: CreateIndexKind,
+						CreateIndexStatement: &CreateIndexStatement{
+							Name: Token{
+								Loc:   Location{Col: 13, Line: 0, Offset: 13},
+								Kind:  IdentifierKind,
+								Value: "i",
+							},
+							Table: Token{
+								Loc:   Location{Col: 18, Line: 0, Offset: 18},
+								Kind:  IdentifierKind,
+								Value: "t",
+							},
+							Exp: &Expression{
+								Literal: &Token{
+									Loc:   Location{Col: 21, Line: 0, Offset: 21},
+									Kind:  IdentifierKind,
+									Value: "name",
+								},
+								Kind: LiteralKind,
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			source: "SELECT *, exclusive",
 			ast: &Ast{
@@ -96,17 +161,17 @@ func TestParse(t *testing.T) {
 					{
 						Kind: SelectKind,
 						SelectStatement: &SelectStatement{
-							item: &[]*selectItem{
+							Item: &[]*SelectItem{
 								{
-									asterisk: true,
+									Asterisk: true,
 								},
 								{
-									exp: &expression{
-										kind: literalKind,
-										literal: &token{
-											loc:   location{col: 10, line: 0},
-											kind:  identifierKind,
-											value: "exclusive",
+									Exp: &Expression{
+										Kind: LiteralKind,
+										Literal: &Token{
+											Loc:   Location{Col: 10, Line: 0, Offset: 10},
+											Kind:  IdentifierKind,
+											Value: "exclusive",
 										},
 									},
 								},
@@ -123,38 +188,135 @@ func TestParse(t *testing.T) {
 					{
 						Kind: SelectKind,
 						SelectStatement: &SelectStatement{
-							item: &[]*selectItem{
-								{
-									exp: &expression{
-										kind: literalKind,
-										literal: &token{
-											loc:   location{col: 7, line: 0},
-											kind:  identifierKind,
-											value: "id",
+							Item: &[]*SelectItem{
+								{
+									Exp: &Expression{
+										Kind: LiteralKind,
+										Literal: &Token{
+											Loc:   Location{Col: 7, Line: 0, Offset: 7},
+											Kind:  IdentifierKind,
+											Value: "id",
 										},
 									},
 								},
 								{
-									exp: &expression{
-										kind: literalKind,
-										literal: &token{
-											loc:   location{col: 11, line: 0},
-											kind:  identifierKind,
-											value: "name",
+									Exp: &Expression{
+										Kind: LiteralKind,
+										Literal: &Token{
+											Loc:   Location{Col: 11, Line: 0, Offset: 11},
+											Kind:  IdentifierKind,
+											Value: "name",
+										},
+									},
+									As: &Token{
+										Loc:   Location{Col: 19, Line: 0, Offset: 19},
+										Kind:  IdentifierKind,
+										Value: "fullname",
+									},
+								},
+							},
+							From: &FromItem{
+								Table: &Token{
+									Loc:   Location{Col: 33, Line: 0, Offset: 33},
+									Kind:  IdentifierKind,
+									Value: "users",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT payload->'a'->>'b', json_extract(payload, '$.b') FROM events",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							Item: &[]*SelectItem{
+								{
+									Exp: &Expression{
+										Kind: BinaryKind,
+										Binary: &BinaryExpression{
+											A: Expression{
+												Kind: BinaryKind,
+												Binary: &BinaryExpression{
+													A: Expression{
+														Kind: LiteralKind,
+														Literal: &Token{
+															Loc:   Location{Col: 7, Line: 0, Offset: 7},
+															Kind:  IdentifierKind,
+															Value: "payload",
+														},
+													},
+													B: Expression{
+														Kind: LiteralKind,
+														Literal: &Token{
+															Loc:   Location{Col: 16, Line: 0, Offset: 16},
+															Kind:  StringKind,
+															Value: "a",
+														},
+													},
+													Op: Token{
+														Loc:   Location{Col: 14, Line: 0, Offset: 14},
+														Kind:  SymbolKind,
+														Value: "->",
+													},
+												},
+											},
+											B: Expression{
+												Kind: LiteralKind,
+												Literal: &Token{
+													Loc:   Location{Col: 22, Line: 0, Offset: 22},
+													Kind:  StringKind,
+													Value: "b",
+												},
+											},
+											Op: Token{
+												Loc:   Location{Col: 19, Line: 0, Offset: 19},
+												Kind:  SymbolKind,
+												Value: "->>",
+											},
 										},
 									},
-									as: &token{
-										loc:   location{col: 19, line: 0},
-										kind:  identifierKind,
-										value: "fullname",
+								},
+								{
+									Exp: &Expression{
+										Kind: CallKind,
+										Call: &CallExpression{
+											Name: Token{
+												Loc:   Location{Col: 27, Line: 0, Offset: 27},
+												Kind:  IdentifierKind,
+												Value: "json_extract",
+											},
+											Args: &[]*Expression{
+												{
+													Kind: LiteralKind,
+													Literal: &Token{
+														Loc:   Location{Col: 40, Line: 0, Offset: 40},
+														Kind:  IdentifierKind,
+														Value: "payload",
+													},
+												},
+												{
+													Kind: LiteralKind,
+													Literal: &Token{
+														Loc:   Location{Col: 49, Line: 0, Offset: 49},
+														Kind:  StringKind,
+														Value: "$.b",
+													},
+												},
+											},
+										},
 									},
 								},
 							},
-							from: &fromItem{
-								table: &token{
-									loc:   location{col: 33, line: 0},
-									kind:  identifierKind,
-									value: "users",
+							From: &FromItem{
+								Table: &Token{
+									Loc:   Location{Col: 61, Line: 0, Offset: 61},
+									Kind:  IdentifierKind,
+									Value: "events",
 								},
 							},
 						},
@@ -162,6 +324,265 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			source: "SELECT CAST(id AS text), '1'::int",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							Item: &[]*SelectItem{
+								{
+									Exp: &Expression{
+										Kind: CastKind,
+										Cast: &CastExpression{
+											Exp: Expression{
+												Kind: LiteralKind,
+												Literal: &Token{
+													Loc:   Location{Col: 12, Line: 0, Offset: 12},
+													Kind:  IdentifierKind,
+													Value: "id",
+												},
+											},
+											Datatype: Token{
+												Loc:   Location{Col: 18, Line: 0, Offset: 18},
+												Kind:  KeywordKind,
+												Value: "text",
+											},
+										},
+									},
+								},
+								{
+									Exp: &Expression{
+										Kind: CastKind,
+										Cast: &CastExpression{
+											Exp: Expression{
+												Kind: LiteralKind,
+												Literal: &Token{
+													Loc:   Location{Col: 25, Line: 0, Offset: 25},
+													Kind:  StringKind,
+													Value: "1",
+												},
+											},
+											Datatype: Token{
+												Loc:   Location{Col: 30, Line: 0, Offset: 30},
+												Kind:  KeywordKind,
+												Value: "int",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT count(*) FROM t GROUP BY a",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							Item: &[]*SelectItem{
+								{
+									Exp: &Expression{
+										Kind: CallKind,
+										Call: &CallExpression{
+											Name: Token{
+												Loc:   Location{Col: 7, Line: 0, Offset: 7},
+												Kind:  IdentifierKind,
+												Value: "count",
+											},
+											Args:     &[]*Expression{},
+											Asterisk: true,
+										},
+									},
+								},
+							},
+							From: &FromItem{
+								Table: &Token{
+									Loc:   Location{Col: 21, Line: 0, Offset: 21},
+									Kind:  IdentifierKind,
+									Value: "t",
+								},
+							},
+							GroupBy: &[]*Expression{
+								{
+									Kind: LiteralKind,
+									Literal: &Token{
+										Loc:   Location{Col: 32, Line: 0, Offset: 32},
+										Kind:  IdentifierKind,
+										Value: "a",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT CASE x WHEN y THEN z END",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							Item: &[]*SelectItem{
+								{
+									Exp: &Expression{
+										Kind: CaseKind,
+										Case: &CaseExpression{
+											Operand: &Expression{
+												Kind: LiteralKind,
+												Literal: &Token{
+													Loc:   Location{Col: 12, Line: 0, Offset: 12},
+													Kind:  IdentifierKind,
+													Value: "x",
+												},
+											},
+											Whens: &[]*WhenClause{
+												{
+													When: &Expression{
+														Kind: LiteralKind,
+														Literal: &Token{
+															Loc:   Location{Col: 19, Line: 0, Offset: 19},
+															Kind:  IdentifierKind,
+															Value: "y",
+														},
+													},
+													Then: &Expression{
+														Kind: LiteralKind,
+														Literal: &Token{
+															Loc:   Location{Col: 26, Line: 0, Offset: 26},
+															Kind:  IdentifierKind,
+															Value: "z",
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "SELECT a NOT BETWEEN b AND c AND d",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SelectKind,
+						SelectStatement: &SelectStatement{
+							Item: &[]*SelectItem{
+								{
+									Exp: &Expression{
+										Kind: BinaryKind,
+										Binary: &BinaryExpression{
+											A: Expression{
+												Kind: BetweenKind,
+												Between: &BetweenExpression{
+													Exp: Expression{
+														Kind: LiteralKind,
+														Literal: &Token{
+															Loc:   Location{Col: 7, Line: 0, Offset: 7},
+															Kind:  IdentifierKind,
+															Value: "a",
+														},
+													},
+													Low: Expression{
+														Kind: LiteralKind,
+														Literal: &Token{
+															Loc:   Location{Col: 21, Line: 0, Offset: 21},
+															Kind:  IdentifierKind,
+															Value: "b",
+														},
+													},
+													High: Expression{
+														Kind: LiteralKind,
+														Literal: &Token{
+															Loc:   Location{Col: 27, Line: 0, Offset: 27},
+															Kind:  IdentifierKind,
+															Value: "c",
+														},
+													},
+													Not: true,
+												},
+											},
+											B: Expression{
+												Kind: LiteralKind,
+												Literal: &Token{
+													Loc:   Location{Col: 33, Line: 0, Offset: 33},
+													Kind:  IdentifierKind,
+													Value: "d",
+												},
+											},
+											Op: Token{
+												Loc:   Location{Col: 29, Line: 0, Offset: 29},
+												Kind:  KeywordKind,
+												Value: "and",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "BEGIN; Commit; rollback",
+			ast: &Ast{
+				Statements: []*Statement{
+					{Kind: BeginKind},
+					{Kind: CommitKind},
+					{Kind: RollbackKind},
+				},
+			},
+		},
+		{
+			source: "SAVEPOINT a; ROLLBACK TO SAVEPOINT a; rollback to a; RELEASE SAVEPOINT a; release a",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: SavepointKind,
+						SavepointStatement: &SavepointStatement{
+							Name: Token{Loc: Location{Col: 10, Line: 0, Offset: 10}, Kind: IdentifierKind, Value: "a"},
+						},
+					},
+					{
+						Kind: RollbackToSavepointKind,
+						SavepointStatement: &SavepointStatement{
+							Name: Token{Loc: Location{Col: 35, Line: 0, Offset: 35}, Kind: IdentifierKind, Value: "a"},
+						},
+					},
+					{
+						Kind: RollbackToSavepointKind,
+						SavepointStatement: &SavepointStatement{
+							Name: Token{Loc: Location{Col: 50, Line: 0, Offset: 50}, Kind: IdentifierKind, Value: "a"},
+						},
+					},
+					{
+						Kind: ReleaseSavepointKind,
+						SavepointStatement: &SavepointStatement{
+							Name: Token{Loc: Location{Col: 71, Line: 0, Offset: 71}, Kind: IdentifierKind, Value: "a"},
+						},
+					},
+					{
+						Kind: ReleaseSavepointKind,
+						SavepointStatement: &SavepointStatement{
+							Name: Token{Loc: Location{Col: 82, Line: 0, Offset: 82}, Kind: IdentifierKind, Value: "a"},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -170,3 +591,211 @@ func TestParse(t *testing.T) {
 		assert.Equal(t, test.ast, ast, test.source)
 	}
 }
+
+func TestParse_NonReservedKeywords(t *testing.T) {
+	sources := []string{
+		"SELECT json, row, rows AS to FROM to",
+		"INSERT INTO partition VALUES (1)",
+		"SELECT sum(preceding) OVER (PARTITION BY partition ORDER BY following ROWS BETWEEN 1 PRECEDING AND 1 FOLLOWING) FROM rows",
+		"SELECT escape LIKE 'a!%' ESCAPE '!' FROM t",
+		"ROLLBACK TO SAVEPOINT to",
+	}
+
+	for _, source := range sources {
+		ast, err := Parse(source)
+		assert.Nil(t, err, source)
+		assert.Equal(t, 1, len(ast.Statements), source)
+	}
+
+	ast, err := Parse("SELECT json FROM rows")
+	assert.Nil(t, err)
+	slct := ast.Statements[0].SelectStatement
+	assert.Equal(t, IdentifierKind, (*slct.Item)[0].Exp.Literal.Kind)
+	assert.Equal(t, IdentifierKind, slct.From.Table.Kind)
+
+	// Reserved keywords still can't name anything
+	_, err = Parse("SELECT order FROM t")
+	assert.NotNil(t, err)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		source   string
+		loc      Location
+		got      string
+		expected []string
+		msg      string
+	}{
+		{
+			source:   "SELECT a FROM",
+			loc:      Location{Line: 0, Col: 9, Offset: 9},
+			expected: []string{"identifier"},
+			msg:      "Expected FROM item",
+		},
+		{
+			source:   "SELECT a FROM t WHERE GROUP BY a",
+			loc:      Location{Line: 0, Col: 22, Offset: 22},
+			got:      "group",
+			expected: []string{"expression"},
+			msg:      "Expected WHERE condition",
+		},
+		{
+			source:   "CREATE INDEX i t (a)",
+			loc:      Location{Line: 0, Col: 15, Offset: 15},
+			got:      "t",
+			expected: []string{"on"},
+			msg:      "expected ON",
+		},
+		{
+			source:   "SELECT CAST(a text)",
+			loc:      Location{Line: 0, Col: 14, Offset: 14},
+			got:      "text",
+			expected: []string{"AS"},
+			msg:      "Expected AS",
+		},
+		{
+			source:   "INSERT INTO t VALUES ('a', 'b'",
+			loc:      Location{Line: 0, Col: 27, Offset: 27},
+			expected: []string{",", ")"},
+			msg:      "Expected comma",
+		},
+		{
+			source:   "SELECT a FROM t b",
+			loc:      Location{Line: 0, Col: 16, Offset: 16},
+			got:      "b",
+			expected: []string{";"},
+			msg:      "Expected semicolon delimiter between statements",
+		},
+		{
+			source:   "DROP TABLE t",
+			loc:      Location{Line: 0, Col: 0, Offset: 0},
+			got:      "drop",
+			expected: []string{"SELECT", "INSERT", "CREATE", "BEGIN", "COMMIT", "ROLLBACK", "SAVEPOINT", "RELEASE"},
+			msg:      "Expected statement",
+		},
+		{
+			source: "SELECT #",
+			loc:    Location{Line: 0, Col: 7, Offset: 7},
+			msg:    "Unable to lex token after select",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.source)
+
+		var parseErr *ParseError
+		assert.True(t, errors.As(err, &parseErr), test.source)
+		if parseErr == nil {
+			continue
+		}
+
+		assert.Equal(t, test.loc, parseErr.Loc, test.source)
+		assert.Equal(t, test.expected, parseErr.Expected, test.source)
+		assert.Equal(t, test.msg, parseErr.Msg, test.source)
+		if test.got == "" {
+			assert.Nil(t, parseErr.Token, test.source)
+		} else {
+			assert.Equal(t, test.got, parseErr.Token.Value, test.source)
+		}
+	}
+}
+
+func TestParse_Recovery(t *testing.T) {
+	source := `CREATE TABLE t (id INT;
+SELECT id FROM t;
+INSERT INTO t VALUES ('a' 'b');
+SELECT CASE id WHEN 'a' THEN id FROM t;
+SELECT 'ok'`
+	ast, err := Parse(source)
+
+	var errs ParseErrors
+	assert.True(t, errors.As(err, &errs))
+	assert.True(t, errors.Is(err, errs[1]))
+	assert.False(t, errors.Is(err, &ParseError{Msg: errs[1].Msg}))
+
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, []string{
+		`[0,22]: Expected comma, got: ; (expected "," or ")")`,
+		`[2,26]: Expected comma, got: b (expected "," or ")")`,
+		`[3,32]: Expected END, got: from (expected "END")`,
+	}, messages)
+
+	// The valid statements are still parsed
+	assert.Equal(t, 2, len(ast.Statements))
+	assert.Equal(t, "t", ast.Statements[0].SelectStatement.From.Table.Value)
+	assert.Equal(t, "ok", (*ast.Statements[1].SelectStatement.Item)[0].Exp.Literal.Value)
+}
+
+func TestParser_Next(t *testing.T) {
+	source := "-- setup\r\nCREATE TABLE t (id INT, name TEXT);;\r\n" +
+		"INSERT INTO t VALUES (1, 'semi;colon\r\n');\n" +
+		"INSERT INTO t VALUES (2, $body$ E'it\\'s;' $body$);\n" +
+		"/* a ; comment */ SELECT id FROM t GROUP BY id;\n" +
+		"SELECT CASE id WHEN 1 THEN 'ñ' FROM t;\n" +
+		"SELECT name ->> 'ü', id::text FROM t"
+
+	ast, err := Parse(source)
+	var errs ParseErrors
+	assert.True(t, errors.As(err, &errs))
+
+	// Reading a byte at a time cuts every token short at some point
+	for _, r := range []io.Reader{strings.NewReader(source), iotest.OneByteReader(strings.NewReader(source))} {
+		p := NewParser(r)
+
+		var statements []*Statement
+		var streamErrs ParseErrors
+		for {
+			stmt, err := p.Next()
+			if err == io.EOF {
+				break
+			}
+
+			var stmtErrs ParseErrors
+			if errors.As(err, &stmtErrs) {
+				streamErrs = append(streamErrs, stmtErrs...)
+				continue
+			}
+
+			assert.Nil(t, err)
+			statements = append(statements, stmt)
+		}
+
+		assert.Equal(t, ast.Statements, statements)
+		assert.Equal(t, errs, streamErrs)
+
+		_, err = p.Next()
+		assert.Equal(t, io.EOF, err)
+	}
+
+	// Only what's needed for the current statement is held in memory
+	p := NewParser(strings.NewReader(strings.Repeat("INSERT INTO t VALUES (1, 'x');\n", 100000)))
+	count, pending := 0, 0
+	for {
+		_, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+
+		assert.Nil(t, err)
+		count++
+		if len(p.pending) > pending {
+			pending = len(p.pending)
+		}
+	}
+	assert.Equal(t, 100000, count)
+	assert.True(t, pending <= streamChunkSize, pending)
+
+	p = NewParser(strings.NewReader("SELECT 1; SELECT #; SELECT 2;"))
+	_, err = p.Next()
+	assert.Nil(t, err)
+
+	_, err = p.Next()
+	assert.Equal(t, ParseErrors{{Loc: Location{Line: 0, Col: 17, Offset: 17}, Msg: "Unable to lex token after select"}}, err)
+
+	// Lexing can't resume after an invalid token
+	_, err = p.Next()
+	assert.NotNil(t, err)
+}
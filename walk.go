@@ -0,0 +1,279 @@
+package gosql
+
+import (
+	"fmt"
+)
+
+// Node is any node of a parsed Ast: *Ast, *Statement, *SelectStatement,
+// *InsertStatement, *CreateTableStatement, *CreateIndexStatement,
+// *SavepointStatement, *ColumnDefinition, *SelectItem, *FromItem, *OrderByItem,
+// *WindowDefinition or *Expression.
+type Node interface {
+	node()
+}
+
+func (*Ast) node()                  {}
+func (*Statement) node()            {}
+func (*SelectStatement) node()      {}
+func (*InsertStatement) node()      {}
+func (*CreateTableStatement) node() {}
+func (*CreateIndexStatement) node() {}
+func (*SavepointStatement) node()   {}
+func (*ColumnDefinition) node()     {}
+func (*SelectItem) node()           {}
+func (*FromItem) node()             {}
+func (*OrderByItem) node()          {}
+func (*WindowDefinition) node()     {}
+func (*Expression) node()           {}
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, in the order its parts
+// appear in the source.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Ast:
+		for _, stmt := range n.Statements {
+			Walk(v, stmt)
+		}
+	case *Statement:
+		switch n.Kind {
+		case SelectKind:
+			Walk(v, n.SelectStatement)
+		case InsertKind:
+			Walk(v, n.InsertStatement)
+		case CreateTableKind:
+			Walk(v, n.CreateTableStatement)
+		case CreateIndexKind:
+			Walk(v, n.CreateIndexStatement)
+		case SavepointKind, RollbackToSavepointKind, ReleaseSavepointKind:
+			Walk(v, n.SavepointStatement)
+		}
+	case *SelectStatement:
+		walkExpressions(v, n.DistinctOn)
+		if n.Item != nil {
+			for _, item := range *n.Item {
+				Walk(v, item)
+			}
+		}
+		if n.From != nil {
+			Walk(v, n.From)
+		}
+		if n.Where != nil {
+			Walk(v, n.Where)
+		}
+		walkExpressions(v, n.GroupBy)
+		walkOrderBy(v, n.OrderBy)
+	case *InsertStatement:
+		walkExpressions(v, n.Values)
+	case *CreateTableStatement:
+		if n.Cols != nil {
+			for _, col := range *n.Cols {
+				Walk(v, col)
+			}
+		}
+	case *CreateIndexStatement:
+		Walk(v, n.Exp)
+	case *SelectItem:
+		if n.Exp != nil {
+			Walk(v, n.Exp)
+		}
+		if n.Over != nil {
+			Walk(v, n.Over)
+		}
+	case *WindowDefinition:
+		walkExpressions(v, n.PartitionBy)
+		walkOrderBy(v, n.OrderBy)
+	case *OrderByItem:
+		Walk(v, n.Exp)
+	case *Expression:
+		for _, sub := range n.subexpressions() {
+			Walk(v, sub)
+		}
+	case *ColumnDefinition, *FromItem, *SavepointStatement:
+		// No child nodes
+	default:
+		panic(fmt.Sprintf("gosql.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkExpressions(v Visitor, exps *[]*Expression) {
+	if exps == nil {
+		return
+	}
+
+	for _, exp := range *exps {
+		Walk(v, exp)
+	}
+}
+
+func walkOrderBy(v Visitor, items *[]*OrderByItem) {
+	if items == nil {
+		return
+	}
+
+	for _, item := range *items {
+		Walk(v, item)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order, calling f(node) for each
+// node and then f(nil) once its children are done. The children of a node
+// are skipped when f returns false for it.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite traverses an AST in depth-first order, replacing every node with
+// the result of calling f on it once its children have been rewritten,
+// and returns the replacement for node itself. Nodes are updated in place
+// and f must return a non-nil node of the same type it was given.
+func Rewrite(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case *Ast:
+		for i, stmt := range n.Statements {
+			n.Statements[i] = Rewrite(stmt, f).(*Statement)
+		}
+	case *Statement:
+		switch n.Kind {
+		case SelectKind:
+			n.SelectStatement = Rewrite(n.SelectStatement, f).(*SelectStatement)
+		case InsertKind:
+			n.InsertStatement = Rewrite(n.InsertStatement, f).(*InsertStatement)
+		case CreateTableKind:
+			n.CreateTableStatement = Rewrite(n.CreateTableStatement, f).(*CreateTableStatement)
+		case CreateIndexKind:
+			n.CreateIndexStatement = Rewrite(n.CreateIndexStatement, f).(*CreateIndexStatement)
+		case SavepointKind, RollbackToSavepointKind, ReleaseSavepointKind:
+			n.SavepointStatement = Rewrite(n.SavepointStatement, f).(*SavepointStatement)
+		}
+	case *SelectStatement:
+		rewriteExpressions(n.DistinctOn, f)
+		if n.Item != nil {
+			for i, item := range *n.Item {
+				(*n.Item)[i] = Rewrite(item, f).(*SelectItem)
+			}
+		}
+		if n.From != nil {
+			n.From = Rewrite(n.From, f).(*FromItem)
+		}
+		if n.Where != nil {
+			n.Where = Rewrite(n.Where, f).(*Expression)
+		}
+		rewriteExpressions(n.GroupBy, f)
+		rewriteOrderBy(n.OrderBy, f)
+	case *InsertStatement:
+		rewriteExpressions(n.Values, f)
+	case *CreateTableStatement:
+		if n.Cols != nil {
+			for i, col := range *n.Cols {
+				(*n.Cols)[i] = Rewrite(col, f).(*ColumnDefinition)
+			}
+		}
+	case *CreateIndexStatement:
+		n.Exp = Rewrite(n.Exp, f).(*Expression)
+	case *SelectItem:
+		if n.Exp != nil {
+			n.Exp = Rewrite(n.Exp, f).(*Expression)
+		}
+		if n.Over != nil {
+			n.Over = Rewrite(n.Over, f).(*WindowDefinition)
+		}
+	case *WindowDefinition:
+		rewriteExpressions(n.PartitionBy, f)
+		rewriteOrderBy(n.OrderBy, f)
+	case *OrderByItem:
+		n.Exp = Rewrite(n.Exp, f).(*Expression)
+	case *Expression:
+		rewriteSubexpressions(n, f)
+	case *ColumnDefinition, *FromItem, *SavepointStatement:
+		// No child nodes
+	default:
+		panic(fmt.Sprintf("gosql.Rewrite: unexpected node type %T", n))
+	}
+
+	return f(node)
+}
+
+func rewriteExpressions(exps *[]*Expression, f func(Node) Node) {
+	if exps == nil {
+		return
+	}
+
+	for i, exp := range *exps {
+		(*exps)[i] = Rewrite(exp, f).(*Expression)
+	}
+}
+
+func rewriteOrderBy(items *[]*OrderByItem, f func(Node) Node) {
+	if items == nil {
+		return
+	}
+
+	for i, item := range *items {
+		(*items)[i] = Rewrite(item, f).(*OrderByItem)
+	}
+}
+
+func rewriteSubexpressions(e *Expression, f func(Node) Node) {
+	// Nested expressions held by value are overwritten with their
+	// replacement, the ones held by pointer are swapped out
+	rewriteValue := func(exp *Expression) {
+		*exp = *Rewrite(exp, f).(*Expression)
+	}
+
+	rewritePointer := func(exp **Expression) {
+		if *exp != nil {
+			*exp = Rewrite(*exp, f).(*Expression)
+		}
+	}
+
+	switch e.Kind {
+	case BinaryKind:
+		rewriteValue(&e.Binary.A)
+		rewriteValue(&e.Binary.B)
+	case CallKind:
+		rewriteExpressions(e.Call.Args, f)
+	case CastKind:
+		rewriteValue(&e.Cast.Exp)
+	case CaseKind:
+		rewritePointer(&e.Case.Operand)
+		for _, w := range *e.Case.Whens {
+			rewritePointer(&w.When)
+			rewritePointer(&w.Then)
+		}
+		rewritePointer(&e.Case.Else)
+	case InKind:
+		rewriteValue(&e.In.Exp)
+		rewriteExpressions(e.In.List, f)
+	case BetweenKind:
+		rewriteValue(&e.Between.Exp)
+		rewriteValue(&e.Between.Low)
+		rewriteValue(&e.Between.High)
+	case LikeKind:
+		rewriteValue(&e.Like.Exp)
+		rewriteValue(&e.Like.Pattern)
+		rewritePointer(&e.Like.Escape)
+	}
+}
@@ -0,0 +1,127 @@
+package gosql_test
+
+import (
+	"testing"
+
+	"github.com/pdbrito/gosql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspect(t *testing.T) {
+	ast, err := gosql.Parse("SELECT upper(name), CASE WHEN id IN (1, 2) THEN lower(name) END FROM users ORDER BY length(name)")
+	assert.Nil(t, err)
+
+	var calls []string
+	var columns []string
+	gosql.Inspect(ast, func(node gosql.Node) bool {
+		exp, ok := node.(*gosql.Expression)
+		if !ok {
+			return true
+		}
+
+		switch exp.Kind {
+		case gosql.CallKind:
+			calls = append(calls, exp.Call.Name.Value)
+		case gosql.LiteralKind:
+			if exp.Literal.Kind == gosql.IdentifierKind {
+				columns = append(columns, exp.Literal.Value)
+			}
+		}
+
+		return true
+	})
+
+	assert.Equal(t, []string{"upper", "lower", "length"}, calls)
+	assert.Equal(t, []string{"name", "id", "name", "name"}, columns)
+
+	// Returning false skips the children of a node, leaving only the
+	// ORDER BY expressions
+	var items, expressions int
+	gosql.Inspect(ast, func(node gosql.Node) bool {
+		switch node.(type) {
+		case *gosql.SelectItem:
+			items++
+			return false
+		case *gosql.Expression:
+			expressions++
+		}
+
+		return true
+	})
+	assert.Equal(t, 2, items)
+	assert.Equal(t, 2, expressions)
+}
+
+type depthVisitor struct {
+	depth    int
+	maxDepth *int
+}
+
+func (v depthVisitor) Visit(node gosql.Node) gosql.Visitor {
+	if node == nil {
+		return nil
+	}
+
+	if v.depth > *v.maxDepth {
+		*v.maxDepth = v.depth
+	}
+
+	return depthVisitor{v.depth + 1, v.maxDepth}
+}
+
+func TestWalk(t *testing.T) {
+	ast, err := gosql.Parse("INSERT INTO users VALUES (1, 'a')")
+	assert.Nil(t, err)
+
+	// Ast, Statement, InsertStatement and then the values
+	var maxDepth int
+	gosql.Walk(depthVisitor{maxDepth: &maxDepth}, ast)
+	assert.Equal(t, 3, maxDepth)
+
+	ast, err = gosql.Parse("BEGIN; SAVEPOINT a; ROLLBACK TO a; RELEASE a; COMMIT")
+	assert.Nil(t, err)
+
+	var savepoints []string
+	gosql.Inspect(ast, func(node gosql.Node) bool {
+		if sp, ok := node.(*gosql.SavepointStatement); ok {
+			savepoints = append(savepoints, sp.Name.Value)
+		}
+		return true
+	})
+	assert.Equal(t, []string{"a", "a", "a"}, savepoints)
+}
+
+func TestRewrite(t *testing.T) {
+	ast, err := gosql.Parse("CREATE TABLE users (id INT); INSERT INTO users VALUES (1); SELECT id, lower(id::text) FROM users; SAVEPOINT users")
+	assert.Nil(t, err)
+
+	// Prefix every table and savepoint with a tenant and replace calls to lower with upper
+	rewritten := gosql.Rewrite(ast, func(node gosql.Node) gosql.Node {
+		switch n := node.(type) {
+		case *gosql.CreateTableStatement:
+			n.Name.Value = "tenant_" + n.Name.Value
+		case *gosql.InsertStatement:
+			n.Table.Value = "tenant_" + n.Table.Value
+		case *gosql.FromItem:
+			n.Table.Value = "tenant_" + n.Table.Value
+		case *gosql.SavepointStatement:
+			n.Name.Value = "tenant_" + n.Name.Value
+		case *gosql.Expression:
+			if n.Kind == gosql.CallKind && n.Call.Name.Value == "lower" {
+				call := *n.Call
+				call.Name.Value = "upper"
+				return &gosql.Expression{Call: &call, Kind: gosql.CallKind}
+			}
+		}
+
+		return node
+	})
+	assert.Equal(t, ast, rewritten)
+
+	assert.Equal(t, "tenant_users", ast.Statements[0].CreateTableStatement.Name.Value)
+	assert.Equal(t, "tenant_users", ast.Statements[1].InsertStatement.Table.Value)
+	slct := ast.Statements[2].SelectStatement
+	assert.Equal(t, "tenant_users", slct.From.Table.Value)
+	assert.Equal(t, "upper", (*slct.Item)[1].Exp.Call.Name.Value)
+	assert.Equal(t, "tenant_users", ast.Statements[3].SavepointStatement.Name.Value)
+}